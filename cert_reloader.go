@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"os"
+	"sync"
+	"time"
+)
+
+// CertReloader serves a client certificate loaded from files that may be
+// rotated, such as by a cert manager. The files are checked for changes at
+// most once per interval, when a new connection asks for the certificate;
+// connections already established keep the certificate they started with.
+type CertReloader struct {
+	certPath string
+	keyPath  string
+	interval time.Duration
+	notify   func(err error)
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	checked time.Time
+	certMod fileVersion
+	keyMod  fileVersion
+}
+
+// fileVersion identifies a version of a file by its modification time and size.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader returns a CertReloader for the PEM cert and key files,
+// which must hold a valid key pair now.
+// An interval <= 0 makes the files checked on every new connection.
+func NewCertReloader(certPath, keyPath string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{
+		certPath: certPath,
+		keyPath:  keyPath,
+		interval: interval,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// OnReload sets fn to be called after the files changed and were reloaded,
+// with nil on success, or the error that made the reloader keep serving
+// the previous certificate.
+func (r *CertReloader) OnReload(fn func(err error)) *CertReloader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notify = fn
+	return r
+}
+
+// Reload loads the key pair from the files now.
+// On error, the previous certificate is kept.
+func (r *CertReloader) Reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.reload()
+}
+
+func (r *CertReloader) reload() error {
+	certMod, err := statFile(r.certPath)
+	if err != nil {
+		return err
+	}
+	keyMod, err := statFile(r.keyPath)
+	if err != nil {
+		return err
+	}
+	// remember the files even if they're malformed, so they're not
+	// reloaded again until they change
+	r.checked = time.Now()
+	r.certMod, r.keyMod = certMod, keyMod
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return err
+	}
+	r.cert = &cert
+	return nil
+}
+
+// GetClientCertificate returns the latest valid certificate,
+// reloading the files first if they changed. It can be used as
+// tls.Config.GetClientCertificate.
+// The OnReload hook is called without the lock held, so it can call Reload.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	var (
+		notify   func(err error)
+		reloaded bool
+		err      error
+	)
+	if time.Since(r.checked) >= r.interval && r.changed() {
+		err = r.reload()
+		notify, reloaded = r.notify, true
+	}
+	cert := r.cert
+	r.mu.Unlock()
+	if reloaded && notify != nil {
+		notify(err)
+	}
+	return cert, nil
+}
+
+// changed reports whether the files differ from the ones last loaded.
+func (r *CertReloader) changed() bool {
+	r.checked = time.Now()
+	certMod, err := statFile(r.certPath)
+	if err != nil {
+		return false
+	}
+	keyMod, err := statFile(r.keyPath)
+	if err != nil {
+		return false
+	}
+	return certMod != r.certMod || keyMod != r.keyMod
+}
+
+func statFile(path string) (fileVersion, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileVersion{}, err
+	}
+	return fileVersion{modTime: info.ModTime(), size: info.Size()}, nil
+}
+
+// CertFileReloader makes c present the client certificate in the PEM cert and
+// key files, picking up new files when they're rotated, see CertReloader.
+// It replaces certificates added by AddCert, AddCertFile and so on.
+func (c *Client) CertFileReloader(certPath, keyPath string, interval time.Duration) *Client {
+	reloader, err := NewCertReloader(certPath, keyPath, interval)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	return c.UseCertReloader(reloader)
+}
+
+// UseCertReloader makes c present the client certificate served by reloader.
+// It replaces certificates added by AddCert, AddCertFile and so on.
+func (c *Client) UseCertReloader(reloader *CertReloader) *Client {
+	if config := c.judge2genTlsConfig("UseCertReloader"); config != nil {
+		config.GetClientCertificate = reloader.GetClientCertificate
+	}
+	return c
+}
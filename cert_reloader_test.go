@@ -0,0 +1,102 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertFileReloader(t *testing.T) {
+	ca := newTestCA(t)
+	serverCert, _, _ := ca.issue(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.TLS.PeerCertificates[0].SerialNumber)
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.tlsConfig().RootCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	certPath, keyPath := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	writeKeyPair := func(certPEM, keyPEM []byte) {
+		if err := ioutil.WriteFile(certPath, certPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(keyPath, keyPEM, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	serial := func(cert tls.Certificate) string {
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.SerialNumber.String()
+	}
+
+	cert1, certPEM, keyPEM := ca.issue(t)
+	writeKeyPair(certPEM, keyPEM)
+	reloader, err := NewCertReloader(certPath, keyPath, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reloadErrs []error
+	reloader.OnReload(func(err error) {
+		reloadErrs = append(reloadErrs, err)
+		if err != nil {
+			// retrying from the hook must not deadlock
+			_ = reloader.Reload()
+		}
+	})
+	client := New().AddCACert(ca.cert).UseCertReloader(reloader)
+	expectSerial := func(expected string) {
+		t.Helper()
+		client.transport.CloseIdleConnections()
+		var s string
+		if err := client.Get(server.URL).ToString(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s != expected {
+			t.Errorf("expected client certificate %s, got %s", expected, s)
+		}
+	}
+	expectSerial(serial(cert1))
+
+	cert2, certPEM, keyPEM := ca.issue(t)
+	writeKeyPair(certPEM, keyPEM)
+	expectSerial(serial(cert2))
+	if len(reloadErrs) != 1 || reloadErrs[0] != nil {
+		t.Errorf("expected a successful reload notification, got %v", reloadErrs)
+	}
+
+	writeKeyPair([]byte("malformed"), keyPEM)
+	expectSerial(serial(cert2))
+	if len(reloadErrs) != 2 || reloadErrs[1] == nil {
+		t.Errorf("expected a failed reload notification, got %v", reloadErrs)
+	}
+	expectSerial(serial(cert2))
+	if len(reloadErrs) != 2 {
+		t.Errorf("expected unchanged malformed files not to be reloaded again, got %v", reloadErrs)
+	}
+
+	if _, err = New().CertFileReloader(certPath, keyPath, time.Minute).Get(server.URL).Go(); err == nil {
+		t.Error("expected an error for malformed files")
+	}
+}
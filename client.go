@@ -1,14 +1,14 @@
 package httpclient
 
 import (
-	"bytes"
 	"crypto/tls"
-	"encoding/json"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 )
 
@@ -40,41 +40,43 @@ const (
 type CallBackStr func(response *http.Response, body string, err error)
 type CallBack func(response *http.Response, err error)
 
+// DefaultClient is the shared Client used by the package level Get, Post
+// and so on.
+var DefaultClient = New()
+
+// Client holds the long-lived state shared by requests: the transport,
+// timeouts and TLS configuration.
+// A Client should be configured before use; after that it is safe for
+// concurrent use by multiple goroutines, and should be reused rather than
+// created per call so that connections can be pooled.
 type Client struct {
-	url              string
-	queries          map[string]string
-	method           string
-	header           map[string]string
-	contentType      string
-	body             []byte
+	baseURL          string
+	client           *http.Client
 	transport        *http.Transport
 	timeout          time.Duration
 	dialTimeout      time.Duration
 	keepAliveTimeout time.Duration
-	err              error
-}
-
-func (c *Client) DebugString() string {
-	return fmt.Sprintf("[url]: %s\n"+
-		"[method]: %s\n"+
-		"[header]: %v\n"+
-		"[content type]:%s\n"+
-		"[body]:%s\n",
-		c.getFullUrl(), c.method, c.header, c.contentType, c.body)
-}
-
-func (c *Client) getFullUrl() string {
-	u, err := url.Parse(c.url)
-	if err != nil {
-		c.keepOriginErr(err)
-		return err.Error()
-	}
-	query := u.Query()
-	for k, v := range c.queries {
-		query.Add(k, v)
-	}
-	u.RawQuery = query.Encode()
-	return u.String()
+	retry            *RetryPolicy
+	middlewares      []Middleware
+	// transportMiddlewares wrap the transport, see UseTransport
+	transportMiddlewares []Middleware
+	beforeRequest        []func(req *http.Request) error
+	afterResponse        []func(resp *http.Response) error
+	logger               Logger
+	logOptions           LogOptions
+	// caCerts are the CA certificates added to the roots, systemRoots tells
+	// they extend the system roots and customPool that the roots are a pool
+	// set by CertPool, see UseSystemCertPool
+	caCerts      []*x509.Certificate
+	systemRoots  bool
+	customPool   bool
+	pins         map[string]bool
+	pinnedConfig *tls.Config
+	// transportOptions are the options applied to the transport, see setTransport
+	transportOptions []string
+	// curlArgs reproduce the TLS files added to the client, see Request.Curl
+	curlArgs []string
+	err      error
 }
 
 func New() *Client {
@@ -82,136 +84,179 @@ func New() *Client {
 		timeout:          DefaultTimeout,
 		dialTimeout:      DefaultDialTimeout,
 		keepAliveTimeout: DefaultKeepAliveTimeout,
-		header:           make(map[string]string),
 		transport: &http.Transport{
 			Proxy:                 http.ProxyFromEnvironment,
 			IdleConnTimeout:       DefaultIdleConnTimeout,
 			TLSHandshakeTimeout:   DefaultTLSHandshakeTimeout,
 			ExpectContinueTimeout: DefaultExpectContinueTimeout,
-			TLSClientConfig: &tls.Config{
-				InsecureSkipVerify: true,
-			},
+			TLSClientConfig:       &tls.Config{},
 		},
 	}
+	client.resetDialer("New")
+	client.client = &http.Client{
+		Transport: client.transport,
+		Timeout:   client.timeout,
+	}
 	return client
 }
 
-func (c *Client) Get(url string) *Client {
-	return c.ReNew(GET, url)
+func Get(url string) *Request {
+	return DefaultClient.Get(url)
 }
 
-func (c *Client) Post(url string) *Client {
-	return c.ReNew(POST, url)
+func Post(url string) *Request {
+	return DefaultClient.Post(url)
 }
 
-func (c *Client) Put(url string) *Client {
-	return c.ReNew(PUT, url)
+func Put(url string) *Request {
+	return DefaultClient.Put(url)
 }
 
-func (c *Client) Head(url string) *Client {
-	return c.ReNew(HEAD, url)
+func Head(url string) *Request {
+	return DefaultClient.Head(url)
 }
 
-func (c *Client) Delete(url string) *Client {
-	return c.ReNew(DELETE, url)
+func Delete(url string) *Request {
+	return DefaultClient.Delete(url)
 }
 
-func (c *Client) Patch(url string) *Client {
-	return c.ReNew(PATCH, url)
+func Patch(url string) *Request {
+	return DefaultClient.Patch(url)
 }
 
-func (c *Client) Options(url string) *Client {
-	return c.ReNew(OPTIONS, url)
+func Options(url string) *Request {
+	return DefaultClient.Options(url)
 }
 
-func (c *Client) AppendQuery(key, value string) *Client {
-	if c.queries == nil {
-		c.queries = map[string]string{key: value}
-	} else {
-		c.queries[key] = value
-	}
-	return c
+// NewRequest returns a new Request sent by DefaultClient.
+func NewRequest(method, url string) *Request {
+	return DefaultClient.NewRequest(method, url)
 }
 
-func (c *Client) AppendQueries(queries map[string]string) *Client {
-	if len(queries) == 0 {
-		return c
-	}
-	if c.queries == nil {
-		c.queries = make(map[string]string, len(queries))
-	}
-	for k, v := range queries {
-		c.queries[k] = v
-	}
-	return c
+func (c *Client) Get(url string) *Request {
+	return c.NewRequest(GET, url)
 }
 
-func (c *Client) ReNew(method, url string) *Client {
-	c.url = url
-	c.method = method
-	c.header = make(map[string]string)
-	c.contentType = ""
-	c.body = nil
-	c.err = nil
-	return c
+func (c *Client) Post(url string) *Request {
+	return c.NewRequest(POST, url)
 }
 
-func (c *Client) ContentType(contentType string) *Client {
-	c.contentType = contentType
-	return c
+func (c *Client) Put(url string) *Request {
+	return c.NewRequest(PUT, url)
+}
+
+func (c *Client) Head(url string) *Request {
+	return c.NewRequest(HEAD, url)
+}
+
+func (c *Client) Delete(url string) *Request {
+	return c.NewRequest(DELETE, url)
 }
 
-func (c *Client) Header(k, v string) *Client {
-	if k == "" || v == "" {
-		c.keepOriginErr(errors.New("invalid header, key or value is empty"))
-	} else {
-		c.header[k] = v
+func (c *Client) Patch(url string) *Request {
+	return c.NewRequest(PATCH, url)
+}
+
+func (c *Client) Options(url string) *Request {
+	return c.NewRequest(OPTIONS, url)
+}
+
+// NewRequest returns a new Request with the given method and url,
+// which will be sent by c.
+func (c *Client) NewRequest(method, url string) *Request {
+	return newRequest(c, method, url)
+}
+
+// BaseURL sets the url that relative request urls are joined to,
+// so that with BaseURL("http://host/api") Get("/users") requests http://host/api/users.
+// Absolute request urls are used as they are.
+func (c *Client) BaseURL(baseURL string) *Client {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	if !u.IsAbs() {
+		c.keepOriginErr(fmt.Errorf("base url %q is not absolute", baseURL))
+		return c
 	}
+	c.baseURL = baseURL
 	return c
 }
 
-// body can be defined struct, string, map, array(or slice), and so on
-func (c *Client) Body(body interface{}) *Client {
-	var err error
-	switch value := body.(type) {
-	case string:
-		c.body = []byte(value)
-	case []byte:
-		c.body = value
-	default:
-		c.body, err = json.Marshal(body)
-		c.keepOriginErr(err)
+// Transport makes c send requests with transport, instead of the
+// *http.Transport created by New.
+// If transport is an *http.Transport, the timeout and TLS options of c
+// configure it; otherwise they keep an error, so they must be set on
+// transport directly. http.DefaultTransport is never configured, as it's
+// shared by the whole program.
+// Transport must be called before the options configuring the transport,
+// such as DialTimeout or AddCACert, which it would drop; otherwise it keeps an error.
+func (c *Client) Transport(transport http.RoundTripper) *Client {
+	c.client.Transport = transport
+	c.setTransport("Transport", transport)
+	return c
+}
+
+// HTTPClient makes c send requests with a copy of client, keeping its
+// transport, timeout, cookie jar and redirect policy, see Transport.
+// The copy makes Timeout leave client as it is, such as http.DefaultClient,
+// but the options of c still configure its *http.Transport.
+// A nil client.Transport is http.DefaultTransport, which isn't configured.
+func (c *Client) HTTPClient(client *http.Client) *Client {
+	if client == nil {
+		c.keepOriginErr(errors.New("HTTPClient needs a non nil *http.Client"))
+		return c
 	}
+	copied := *client
+	c.client = &copied
+	c.timeout = client.Timeout
+	c.setTransport("HTTPClient", client.Transport)
 	return c
 }
 
 func (c *Client) Timeout(timeout time.Duration) *Client {
 	c.timeout = timeout
+	c.client.Timeout = timeout
 	return c
 }
 
 func (c *Client) DialTimeout(timeout time.Duration) *Client {
+	if c.dialTimeout == timeout {
+		return c
+	}
 	c.dialTimeout = timeout
+	c.resetDialer("DialTimeout")
 	return c
 }
 
 func (c *Client) KeepAliveTimeout(timeout time.Duration) *Client {
+	if c.keepAliveTimeout == timeout {
+		return c
+	}
 	c.keepAliveTimeout = timeout
+	c.resetDialer("KeepAliveTimeout")
 	return c
 }
 
 func (c *Client) IdleConnTimeout(timeout time.Duration) *Client {
-	c.transport.IdleConnTimeout = timeout
+	if transport := c.httpTransport("IdleConnTimeout"); transport != nil {
+		transport.IdleConnTimeout = timeout
+	}
 	return c
 }
 
 func (c *Client) TLSHandshakeTimeout(timeout time.Duration) *Client {
-	c.transport.TLSHandshakeTimeout = timeout
+	if transport := c.httpTransport("TLSHandshakeTimeout"); transport != nil {
+		transport.TLSHandshakeTimeout = timeout
+	}
 	return c
 }
 
 func (c *Client) ExpectContinueTimeout(timeout time.Duration) *Client {
-	c.transport.ExpectContinueTimeout = timeout
+	if transport := c.httpTransport("ExpectContinueTimeout"); transport != nil {
+		transport.ExpectContinueTimeout = timeout
+	}
 	return c
 }
 
@@ -221,56 +266,59 @@ func (c *Client) keepOriginErr(err error) {
 	}
 }
 
-func (c *Client) Do(callback CallBack) {
-	if callback == nil {
+// resetDialer installs a dialer built from the dial and keep-alive timeouts.
+// Connections dialed before are kept in the pool.
+func (c *Client) resetDialer(option string) {
+	transport := c.httpTransport(option)
+	if transport == nil {
 		return
 	}
-	callback(c.Go())
+	transport.DialContext = (&net.Dialer{
+		Timeout:   c.dialTimeout,
+		KeepAlive: c.keepAliveTimeout,
+	}).DialContext
 }
 
-func (c *Client) Go() (*http.Response, error) {
-	if c.err != nil {
-		return nil, c.err
+// setTransport makes transport the one configured by the options of c.
+// It keeps an error if options were applied to the previous transport,
+// as they would be dropped silently.
+func (c *Client) setTransport(option string, transport http.RoundTripper) {
+	if len(c.transportOptions) > 0 {
+		c.keepOriginErr(fmt.Errorf("%s would drop %s set before it, call it first",
+			option, strings.Join(c.transportOptions, ", ")))
 	}
-
-	req, err := c.makeRequest()
-	if err != nil {
-		return nil, fmt.Errorf("make request failed:%q", err)
+	c.transportOptions = nil
+	c.transport, _ = transport.(*http.Transport)
+	if transport == nil || transport == http.DefaultTransport {
+		c.transport = nil
 	}
-	for k, v := range c.queries {
-		req.URL.Query().Add(k, v)
-	}
-	client := c.makeClient()
-	return client.Do(req)
+	c.installPins()
 }
 
-func (c *Client) makeRequest() (*http.Request, error) {
-	var (
-		req *http.Request
-		err error
-	)
-
-	req, err = http.NewRequest(c.method, c.getFullUrl(), bytes.NewReader(c.body))
-	if err != nil {
-		return nil, err
+// httpTransport returns the transport of c, for option to configure it.
+// It returns nil and keeps an error if the transport isn't an *http.Transport,
+// or is http.DefaultTransport.
+func (c *Client) httpTransport(option string) *http.Transport {
+	if c.transport == nil {
+		if c.client.Transport == nil || c.client.Transport == http.DefaultTransport {
+			c.keepOriginErr(fmt.Errorf("%s would change http.DefaultTransport, which is shared by the whole program", option))
+		} else {
+			c.keepOriginErr(fmt.Errorf("%s needs an *http.Transport, but the transport is %T", option, c.client.Transport))
+		}
+		return nil
 	}
-
-	req.Header.Set(HeaderContentType, c.contentType)
-
-	for k, v := range c.header {
-		req.Header.Set(k, v)
+	// the pins are installed again by setTransport, so they're never dropped
+	if option != "New" && option != "PinSHA256" && !containsString(c.transportOptions, option) {
+		c.transportOptions = append(c.transportOptions, option)
 	}
-	return req, nil
+	return c.transport
 }
 
-func (c *Client) makeClient() http.Client {
-	c.transport.DialContext = (&net.Dialer{
-		Timeout:   c.dialTimeout,
-		KeepAlive: c.keepAliveTimeout,
-	}).DialContext
-	client := http.Client{
-		Transport: c.transport,
-		Timeout:   c.timeout,
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
 	}
-	return client
+	return false
 }
@@ -1,11 +1,24 @@
 package httpclient
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 type Pet struct {
@@ -231,3 +244,394 @@ func TestPost(t *testing.T) {
 		t.Log("body is a number, response is:", body)
 	})
 }
+
+func TestGoContext(t *testing.T) {
+	done := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
+		}
+	}))
+	defer server.Close()
+	defer close(done)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	New().Get(server.URL).DoContext(ctx, func(response *http.Response, err error) {
+		if err == nil {
+			_ = response.Body.Close()
+			t.Fatal("expected an error for an expired context")
+		}
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	})
+}
+
+func TestClientConcurrent(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.Query().Get("n") + r.Header.Get("n")))
+	}))
+	defer server.Close()
+
+	client := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(n string) {
+			defer wg.Done()
+			client.Get(server.URL).AppendQuery("n", n).Header("n", n).Do(func(response *http.Response, err error) {
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer func() {
+					_ = response.Body.Close()
+				}()
+				body, _ := ioutil.ReadAll(response.Body)
+				if string(body) != n+n {
+					t.Errorf("expected response %s, got %s", n+n, body)
+				}
+			})
+		}(string(rune('a' + i)))
+	}
+	wg.Wait()
+}
+
+// newCountingServer returns a server that counts the connections it accepts.
+func newCountingServer(conns *int64) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pong"))
+	}))
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(conns, 1)
+		}
+	}
+	server.Start()
+	return server
+}
+
+func drain(response *http.Response, err error) {
+	if err != nil {
+		return
+	}
+	_, _ = ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+}
+
+func TestConnectionReuse(t *testing.T) {
+	var conns int64
+	server := newCountingServer(&conns)
+	defer server.Close()
+
+	client := New()
+	for i := 0; i < 5; i++ {
+		client.Get(server.URL).Do(drain)
+	}
+	if atomic.LoadInt64(&conns) != 1 {
+		t.Errorf("expected 1 connection for sequential requests, got %d", conns)
+	}
+
+	client.DialTimeout(DefaultDialTimeout).KeepAliveTimeout(DefaultKeepAliveTimeout)
+	client.Get(server.URL).Do(drain)
+	if atomic.LoadInt64(&conns) != 1 {
+		t.Errorf("expected unchanged timeouts to keep the pooled connection, got %d connections", conns)
+	}
+}
+
+func BenchmarkSharedClient(b *testing.B) {
+	var conns int64
+	server := newCountingServer(&conns)
+	defer server.Close()
+
+	client := New()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		client.Get(server.URL).Do(drain)
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&conns))/float64(b.N), "conns/op")
+}
+
+func BenchmarkNewClientPerCall(b *testing.B) {
+	var conns int64
+	server := newCountingServer(&conns)
+	defer server.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		New().Get(server.URL).Do(drain)
+	}
+	b.ReportMetric(float64(atomic.LoadInt64(&conns))/float64(b.N), "conns/op")
+}
+
+func TestMultiValues(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"query":  r.URL.RawQuery,
+			"accept": r.Header.Values("Accept"),
+			"del":    r.Header.Get("X-Del"),
+		})
+	}))
+	defer server.Close()
+
+	var result struct {
+		Query  string
+		Accept []string
+		Del    string
+	}
+	err := New().Get(server.URL+"?b=2&a=1").
+		AddQuery("tag", "a").
+		AddQuery("tag", "b").
+		SetQuery("page", "1").
+		SetQuery("page", "2").
+		AddQuery("gone", "x").
+		DelQuery("gone").
+		Queries(url.Values{"id": {"3", "4"}}).
+		AddHeader("Accept", ContentTypeJson).
+		AddHeader("Accept", ContentTypeXml).
+		SetHeader("X-Del", "x").
+		DelHeader("X-Del").
+		Headers(http.Header{"Accept": {ContentTypeText}}).
+		ToJSON(&result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const query = "b=2&a=1&id=3&id=4&page=2&tag=a&tag=b"
+	if result.Query != query {
+		t.Errorf("expected query %s, got %s", query, result.Query)
+	}
+	if len(result.Accept) != 3 || result.Accept[0] != ContentTypeJson || result.Accept[2] != ContentTypeText {
+		t.Errorf("unexpected Accept values %v", result.Accept)
+	}
+	if result.Del != "" {
+		t.Errorf("expected deleted header to be absent, got %s", result.Del)
+	}
+}
+
+func TestBaseURLAndPathParams(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.URL.EscapedPath() + "?" + r.URL.RawQuery))
+	}))
+	defer server.Close()
+
+	client := New().BaseURL(server.URL + "/api/")
+	cases := []struct {
+		request  *Request
+		expected string
+	}{
+		{request: client.Get("/users/{id}/pets/{pet}").PathParam("id", "42").PathParam("pet", "miu miu"), expected: "/api/users/42/pets/miu%20miu?"},
+		{request: client.Get("users/{id}").PathParams(map[string]string{"id": "a/b"}).AppendQuery("q", "1"), expected: "/api/users/a%2Fb?q=1"},
+		{request: client.Get(server.URL + "/abs"), expected: "/abs?"},
+		{request: client.Get(`/search?filter={"a":1}`), expected: `/api/search?filter={"a":1}`},
+		{request: client.Get("/users/{id}?tpl={id}").PathParam("id", "42"), expected: "/api/users/42?tpl={id}"},
+	}
+	for _, c := range cases {
+		var s string
+		if err := c.request.ToString(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s != c.expected {
+			t.Errorf("expected %s, got %s", c.expected, s)
+		}
+	}
+
+	if _, err := client.Get("/users/{id}").Go(); err == nil {
+		t.Error("expected an error for a missing path param")
+	}
+	if _, err := New().BaseURL("/relative").Get("/users").Go(); err == nil {
+		t.Error("expected an error for a relative base url")
+	}
+}
+
+func TestStreamingBody(t *testing.T) {
+	const size = 64 << 20
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := io.Copy(ioutil.Discard, r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		_, _ = fmt.Fprintf(w, "%d %d", r.ContentLength, n)
+	}))
+	defer server.Close()
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	var s string
+	err := New().Put(server.URL).Body(io.LimitReader(zeroReader{}, size)).ToString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runtime.ReadMemStats(&after)
+	if expected := fmt.Sprintf("-1 %d", size); s != expected {
+		t.Errorf("expected a chunked body, server got %s, expected %s", s, expected)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > size/8 {
+		t.Errorf("expected the body to be streamed, but %d bytes were allocated", allocated)
+	}
+
+	err = New().Put(server.URL).Body(io.LimitReader(zeroReader{}, 10)).ContentLength(10).ToString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "10 10" {
+		t.Errorf("expected a body with known length, server got %s", s)
+	}
+
+	file, err := os.Open(filepath.Join("testdata", "ca.crt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	reopen := func() (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader("abc")), nil
+	}
+	for name, request := range map[string]*Request{
+		"body":      New().Put(server.URL).Body(file).Body(struct{ io.Reader }{strings.NewReader("abc")}),
+		"body func": New().Put(server.URL).Body(file).BodyFunc(reopen),
+	} {
+		if err = request.ToString(&s); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if s != "-1 3" {
+			t.Errorf("%s: expected the length of the replaced file dropped, server got %s", name, s)
+		}
+	}
+}
+
+func TestBodyFunc(t *testing.T) {
+	const body = "rewound body"
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, "/retry", http.StatusTemporaryRedirect)
+			return
+		}
+		got, _ := ioutil.ReadAll(r.Body)
+		if string(got) != body {
+			t.Errorf("expected body %q, got %q", body, got)
+		}
+		if r.ContentLength != int64(len(body)) {
+			t.Errorf("expected content length %d, got %d", len(body), r.ContentLength)
+		}
+		if atomic.AddInt64(&attempts, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	var opened int64
+	resp, err := New().Retry(RetryPolicy{MinBackoff: time.Millisecond}).
+		Put(server.URL + "/redirect").
+		BodyFunc(func() (io.ReadCloser, error) {
+			atomic.AddInt64(&opened, 1)
+			return ioutil.NopCloser(strings.NewReader(body)), nil
+		}).
+		ContentLength(int64(len(body))).
+		ExpectOK().
+		Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if attempts != 2 || opened != 4 {
+		t.Errorf("expected 2 attempts with 4 opened bodies, got %d attempts and %d bodies", attempts, opened)
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func TestTransport(t *testing.T) {
+	fake := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusTeapot,
+			Header:     make(http.Header),
+			Body:       ioutil.NopCloser(strings.NewReader(req.URL.Path)),
+			Request:    req,
+		}, nil
+	})
+	var s string
+	err := New().Transport(fake).Get("http://fake/teapot").CheckStatus(http.StatusTeapot).ToString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "/teapot" {
+		t.Errorf("expected the fake transport response, got %s", s)
+	}
+
+	foreign := map[string]*Client{
+		"InsecureSkipVerify": New().Transport(fake).InsecureSkipVerify(true),
+		"IdleConnTimeout":    New().Transport(fake).IdleConnTimeout(time.Second),
+		"DialTimeout":        New().Transport(fake).DialTimeout(time.Second),
+		"AddCACert":          New().HTTPClient(&http.Client{}).AddCACert(newTestCA(t).cert),
+	}
+	for name, client := range foreign {
+		_, err = client.Get("http://fake/").Go()
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected a descriptive error for a foreign transport, got %v", name, err)
+		}
+	}
+
+	swapped := map[string]*Client{
+		"DialTimeout": New().DialTimeout(time.Second).Transport(&http.Transport{}),
+		"AddCACert":   New().AddCACert(newTestCA(t).cert).HTTPClient(&http.Client{Transport: &http.Transport{}}),
+	}
+	for name, client := range swapped {
+		_, err = client.Get("http://fake/").Go()
+		if err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: expected an error for an option dropped by the new transport, got %v", name, err)
+		}
+	}
+	if _, err = New().HTTPClient(nil).Get("http://fake/").Go(); err == nil {
+		t.Error("expected an error for a nil *http.Client")
+	}
+	shared := New().HTTPClient(http.DefaultClient).Timeout(time.Minute).Transport(http.DefaultTransport).IdleConnTimeout(time.Hour)
+	if http.DefaultClient.Timeout != 0 || http.DefaultTransport.(*http.Transport).IdleConnTimeout == time.Hour {
+		t.Error("expected http.DefaultClient and http.DefaultTransport left as they are")
+	}
+	if _, err = shared.Get("http://fake/").Go(); err == nil || !strings.Contains(err.Error(), "http.DefaultTransport") {
+		t.Errorf("expected an error for configuring http.DefaultTransport, got %v", err)
+	}
+
+	transport := &http.Transport{}
+	jar := &cookieJar{}
+	client := New().HTTPClient(&http.Client{Transport: transport, Jar: jar}).
+		IdleConnTimeout(time.Second).
+		InsecureSkipVerify(true).
+		Timeout(time.Minute)
+	if transport.IdleConnTimeout != time.Second || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Error("expected the options to configure the injected *http.Transport")
+	}
+	if client.client.Timeout != time.Minute {
+		t.Error("expected Timeout to configure the injected *http.Client")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "1"})
+	}))
+	defer server.Close()
+	client.Get(server.URL).Do(drain)
+	if len(jar.cookies) != 1 {
+		t.Error("expected the injected *http.Client cookie jar to be used")
+	}
+}
+
+type cookieJar struct {
+	cookies []*http.Cookie
+}
+
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.cookies = append(j.cookies, cookies...)
+}
+
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.cookies
+}
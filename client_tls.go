@@ -3,17 +3,141 @@ package httpclient
 import (
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
 	"io/ioutil"
+	"path/filepath"
 )
 
+// TlsConfig replaces the TLS config of the transport, with the settings
+// applied before. The roots of config are used as set by CertPool.
 func (c *Client) TlsConfig(config *tls.Config) *Client {
-	c.transport.TLSClientConfig = config
+	if transport := c.httpTransport("TlsConfig"); transport != nil {
+		transport.TLSClientConfig = config
+		c.caCerts, c.systemRoots, c.customPool = nil, false, config != nil && config.RootCAs != nil
+		c.installPins()
+	}
 	return c
 }
 
+// InsecureSkipVerify controls whether the server certificate chain and host
+// name are verified. By default they're verified against the system roots,
+// or the ones added by AddCAFile, AddCAContent and so on.
+// Skipping verification makes the connection open to man-in-the-middle
+// attacks; use it only for testing.
 func (c *Client) InsecureSkipVerify(skip bool) *Client {
-	c.judge2genTlsConfig()
-	c.transport.TLSClientConfig.InsecureSkipVerify = skip
+	if config := c.judge2genTlsConfig("InsecureSkipVerify"); config != nil {
+		config.InsecureSkipVerify = skip
+	}
+	return c
+}
+
+// TLSPolicy is a named set of TLS versions, cipher suites and curves,
+// see TLSModern and TLSIntermediate.
+type TLSPolicy struct {
+	MinVersion       uint16
+	MaxVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+}
+
+var (
+	// TLSModern allows TLS 1.3 only, for servers known to support it.
+	TLSModern = TLSPolicy{
+		MinVersion: tls.VersionTLS13,
+	}
+	// TLSIntermediate allows TLS 1.2, with forward secret AEAD cipher suites only, and TLS 1.3.
+	TLSIntermediate = TLSPolicy{
+		MinVersion: tls.VersionTLS12,
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256, tls.CurveP384},
+	}
+)
+
+// TLSPolicy applies the versions, cipher suites and curves of policy,
+// zero fields leave the current settings as they are.
+func (c *Client) TLSPolicy(policy TLSPolicy) *Client {
+	if policy.MinVersion != 0 {
+		c.MinTLSVersion(policy.MinVersion)
+	}
+	if policy.MaxVersion != 0 {
+		c.MaxTLSVersion(policy.MaxVersion)
+	}
+	if policy.CipherSuites != nil {
+		c.CipherSuites(policy.CipherSuites...)
+	}
+	if policy.CurvePreferences != nil {
+		c.CurvePreferences(policy.CurvePreferences...)
+	}
+	return c
+}
+
+// MinTLSVersion sets the minimum TLS version, such as tls.VersionTLS12.
+func (c *Client) MinTLSVersion(version uint16) *Client {
+	if config := c.judge2genTlsConfig("MinTLSVersion"); config != nil {
+		config.MinVersion = version
+	}
+	return c
+}
+
+// MaxTLSVersion sets the maximum TLS version, such as tls.VersionTLS13.
+func (c *Client) MaxTLSVersion(version uint16) *Client {
+	if config := c.judge2genTlsConfig("MaxTLSVersion"); config != nil {
+		config.MaxVersion = version
+	}
+	return c
+}
+
+// CipherSuites sets the cipher suites enabled for TLS 1.2 and lower.
+// TLS 1.3 cipher suites aren't configurable.
+func (c *Client) CipherSuites(suites ...uint16) *Client {
+	if config := c.judge2genTlsConfig("CipherSuites"); config != nil {
+		config.CipherSuites = suites
+	}
+	return c
+}
+
+// CurvePreferences sets the elliptic curves used in ECDHE handshakes, in preference order.
+func (c *Client) CurvePreferences(curves ...tls.CurveID) *Client {
+	if config := c.judge2genTlsConfig("CurvePreferences"); config != nil {
+		config.CurvePreferences = curves
+	}
+	return c
+}
+
+// ServerName overrides the server name sent with SNI and verified against
+// the server certificate, which default to the host of the request url.
+func (c *Client) ServerName(name string) *Client {
+	if config := c.judge2genTlsConfig("ServerName"); config != nil {
+		config.ServerName = name
+	}
+	return c
+}
+
+// NextProtos sets the protocols offered with ALPN, in preference order.
+func (c *Client) NextProtos(protos ...string) *Client {
+	if config := c.judge2genTlsConfig("NextProtos"); config != nil {
+		config.NextProtos = protos
+	}
+	return c
+}
+
+// TLSSessionCache enables TLS session resumption, with an LRU cache of
+// capacity sessions; capacity <= 0 uses the default capacity.
+// Pins set by PinSHA256 are checked on full handshakes, so a resumed
+// session is one that was pinned when it was established.
+func (c *Client) TLSSessionCache(capacity int) *Client {
+	if config := c.judge2genTlsConfig("TLSSessionCache"); config != nil {
+		config.ClientSessionCache = tls.NewLRUClientSessionCache(capacity)
+	}
 	return c
 }
 
@@ -23,24 +147,110 @@ func (c *Client) AddCAFile(cafile string) *Client {
 		c.keepOriginErr(err)
 		return c
 	}
+	c.curlArgs = append(c.curlArgs, "--cacert", cafile)
 	return c.AddCAContent(content)
 }
 
+// AddCAContent adds the CA certificates in cacontent, which can be a PEM
+// bundle or DER encoded certificates, to the roots used to verify servers.
+// It keeps an error if no certificate is found.
 func (c *Client) AddCAContent(cacontent []byte) *Client {
-	c.judge2genPool()
-	c.transport.TLSClientConfig.RootCAs.AppendCertsFromPEM(cacontent)
+	certs, err := parseCerts(cacontent)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	if len(certs) == 0 {
+		c.keepOriginErr(errors.New("no CA certificate found"))
+		return c
+	}
+	for _, cert := range certs {
+		c.AddCACert(cert)
+	}
+	return c
+}
+
+// AddCADir adds the CA certificates in the files of dir, see AddCAContent.
+// Files that are neither PEM nor DER certificates are skipped,
+// subdirectories aren't walked.
+func (c *Client) AddCADir(dir string) *Client {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	found := 0
+	for _, info := range infos {
+		if !info.Mode().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, info.Name())
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			c.keepOriginErr(err)
+			return c
+		}
+		certs, err := parseCerts(content)
+		if err != nil {
+			if !isPEM(content) {
+				continue
+			}
+			c.keepOriginErr(fmt.Errorf("parse %s failed: %w", path, err))
+			return c
+		}
+		for _, cert := range certs {
+			c.AddCACert(cert)
+		}
+		found += len(certs)
+	}
+	if found == 0 {
+		c.keepOriginErr(fmt.Errorf("no CA certificate found in %s", dir))
+	}
+	c.curlArgs = append(c.curlArgs, "--capath", dir)
+	return c
+}
+
+// UseSystemCertPool makes the CA certificates added by AddCAFile, AddCAContent
+// and so on extend the system roots, instead of replacing them,
+// whether they're added before or after it.
+// It keeps an error after CertPool, whose pool is used as it is.
+func (c *Client) UseSystemCertPool() *Client {
+	config := c.judge2genTlsConfig("UseSystemCertPool")
+	if config == nil {
+		return c
+	}
+	if c.customPool {
+		c.keepOriginErr(errors.New("UseSystemCertPool can't extend the pool set by CertPool"))
+		return c
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	for _, cert := range c.caCerts {
+		pool.AddCert(cert)
+	}
+	config.RootCAs = pool
+	c.systemRoots = true
 	return c
 }
 
 func (c *Client) AddCACert(cert *x509.Certificate) *Client {
-	c.judge2genPool()
-	c.transport.TLSClientConfig.RootCAs.AddCert(cert)
+	if pool := c.judge2genPool("AddCACert"); pool != nil {
+		pool.AddCert(cert)
+		c.caCerts = append(c.caCerts, cert)
+	}
 	return c
 }
 
+// CertPool sets the roots used to verify servers to pool, replacing the
+// CA certificates added before; the ones added after are added to pool.
 func (c *Client) CertPool(pool *x509.CertPool) *Client {
-	c.judge2genTlsConfig()
-	c.transport.TLSClientConfig.RootCAs = pool
+	if config := c.judge2genTlsConfig("CertPool"); config != nil {
+		config.RootCAs = pool
+		c.caCerts, c.systemRoots, c.customPool = nil, false, true
+	}
 	return c
 }
 
@@ -55,6 +265,7 @@ func (c *Client) AddCertFile(cert, key string) *Client {
 		c.keepOriginErr(err)
 		return c
 	}
+	c.curlArgs = append(c.curlArgs, "--cert", cert, "--key", key)
 	return c.AddCertContent(certCotent, keyContent)
 }
 
@@ -68,20 +279,84 @@ func (c *Client) AddCertContent(certContent, keyContent []byte) *Client {
 }
 
 func (c *Client) AddCert(cert tls.Certificate) *Client {
-	c.judge2genTlsConfig()
-	c.transport.TLSClientConfig.Certificates = append(c.transport.TLSClientConfig.Certificates, cert)
+	if config := c.judge2genTlsConfig("AddCert"); config != nil {
+		config.Certificates = append(config.Certificates, cert)
+	}
 	return c
 }
 
-func (c *Client) judge2genTlsConfig() {
-	if c.transport.TLSClientConfig == nil {
-		c.transport.TLSClientConfig = new(tls.Config)
+// judge2genTlsConfig returns the TLS config of the transport, creating it if needed.
+// It returns nil and keeps an error if the transport isn't an *http.Transport.
+func (c *Client) judge2genTlsConfig(option string) *tls.Config {
+	transport := c.httpTransport(option)
+	if transport == nil {
+		return nil
 	}
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = new(tls.Config)
+	}
+	return transport.TLSClientConfig
 }
 
-func (c *Client) judge2genPool() {
-	c.judge2genTlsConfig()
-	if c.transport.TLSClientConfig.RootCAs == nil {
-		c.transport.TLSClientConfig.RootCAs = x509.NewCertPool()
+// parseCerts parses the certificates in a PEM bundle, or in DER if content isn't PEM.
+// Non certificate PEM blocks are skipped.
+func parseCerts(content []byte) ([]*x509.Certificate, error) {
+	var (
+		certs  []*x509.Certificate
+		block  *pem.Block
+		rest   = content
+		hasPEM bool
+	)
+	for {
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		hasPEM = true
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if hasPEM {
+		return certs, nil
+	}
+	return x509.ParseCertificates(content)
+}
+
+func isPEM(content []byte) bool {
+	block, _ := pem.Decode(content)
+	return block != nil
+}
+
+// tlsClientConfig returns the TLS config of the transport, if any.
+func (c *Client) tlsClientConfig() *tls.Config {
+	if c.transport == nil {
+		return nil
+	}
+	return c.transport.TLSClientConfig
+}
+
+func (c *Client) judge2genPool(option string) *x509.CertPool {
+	config := c.judge2genTlsConfig(option)
+	if config == nil {
+		return nil
+	}
+	if config.RootCAs != nil {
+		return config.RootCAs
+	}
+	config.RootCAs = x509.NewCertPool()
+	if c.systemRoots {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			c.keepOriginErr(err)
+			return nil
+		}
+		config.RootCAs = pool
 	}
+	return config.RootCAs
 }
@@ -0,0 +1,178 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Codec encodes request bodies and decodes response bodies of a content type.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	// ContentType is the content type the codec is registered for,
+	// and the Content-Type set on requests it encodes.
+	ContentType() string
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = make(map[string]Codec)
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(XMLCodec{})
+	RegisterCodec(FormCodec{})
+	RegisterCodec(TextCodec{})
+}
+
+// RegisterCodec makes codec used for the media type of its ContentType,
+// replacing any codec registered for it before.
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[mediaType(codec.ContentType())] = codec
+}
+
+// CodecFor returns the codec registered for the media type of contentType,
+// ignoring its parameters such as charset.
+// Media types with a +json or +xml suffix fall back to the JSON and XML codecs.
+func CodecFor(contentType string) (Codec, bool) {
+	mt := mediaType(contentType)
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if codec, ok := codecs[mt]; ok {
+		return codec, true
+	}
+	switch {
+	case strings.HasSuffix(mt, "+json"):
+		codec, ok := codecs[ContentTypeJson]
+		return codec, ok
+	case strings.HasSuffix(mt, "+xml"), strings.HasSuffix(mt, "/xml"):
+		codec, ok := codecs[ContentTypeXml]
+		return codec, ok
+	}
+	return nil, false
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+// JSONCodec is the codec for ContentTypeJson, and the default one for request bodies.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJson
+}
+
+// XMLCodec is the codec for ContentTypeXml.
+type XMLCodec struct{}
+
+func (XMLCodec) Marshal(v interface{}) ([]byte, error) {
+	return xml.Marshal(v)
+}
+
+func (XMLCodec) Unmarshal(data []byte, v interface{}) error {
+	return xml.Unmarshal(data, v)
+}
+
+func (XMLCodec) ContentType() string {
+	return ContentTypeXml
+}
+
+// FormCodec is the codec for ContentTypeForm.
+// It marshals url.Values, map[string][]string and map[string]string,
+// and unmarshals into pointers to them.
+type FormCodec struct{}
+
+func (FormCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case url.Values:
+		return []byte(value.Encode()), nil
+	case map[string][]string:
+		return []byte(url.Values(value).Encode()), nil
+	case map[string]string:
+		values := make(url.Values, len(value))
+		for k, v := range value {
+			values.Set(k, v)
+		}
+		return []byte(values.Encode()), nil
+	}
+	return nil, fmt.Errorf("form codec can't marshal %T", v)
+}
+
+func (FormCodec) Unmarshal(data []byte, v interface{}) error {
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case *url.Values:
+		*value = values
+	case *map[string][]string:
+		*value = values
+	case *map[string]string:
+		*value = make(map[string]string, len(values))
+		for k := range values {
+			(*value)[k] = values.Get(k)
+		}
+	default:
+		return fmt.Errorf("form codec can't unmarshal into %T", v)
+	}
+	return nil
+}
+
+func (FormCodec) ContentType() string {
+	return ContentTypeForm
+}
+
+// TextCodec is the codec for ContentTypeText.
+// It marshals strings, byte slices and fmt.Stringers,
+// and unmarshals into *string and *[]byte.
+type TextCodec struct{}
+
+func (TextCodec) Marshal(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	case fmt.Stringer:
+		return []byte(value.String()), nil
+	}
+	return nil, fmt.Errorf("text codec can't marshal %T", v)
+}
+
+func (TextCodec) Unmarshal(data []byte, v interface{}) error {
+	switch value := v.(type) {
+	case *string:
+		*value = string(data)
+	case *[]byte:
+		*value = append((*value)[:0], data...)
+	default:
+		return fmt.Errorf("text codec can't unmarshal into %T", v)
+	}
+	return nil
+}
+
+func (TextCodec) ContentType() string {
+	return ContentTypeText
+}
@@ -0,0 +1,127 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// csvCodec encodes a slice of strings as a single csv line.
+type csvCodec struct{}
+
+func (csvCodec) Marshal(v interface{}) ([]byte, error) {
+	fields, ok := v.([]string)
+	if !ok {
+		return nil, fmt.Errorf("csv codec can't marshal %T", v)
+	}
+	var buf bytes.Buffer
+	for i, f := range fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(f)
+	}
+	return buf.Bytes(), nil
+}
+
+func (csvCodec) Unmarshal(data []byte, v interface{}) error {
+	fields, ok := v.(*[]string)
+	if !ok {
+		return fmt.Errorf("csv codec can't unmarshal into %T", v)
+	}
+	*fields = nil
+	for _, f := range bytes.Split(data, []byte(",")) {
+		*fields = append(*fields, string(f))
+	}
+	return nil
+}
+
+func (csvCodec) ContentType() string {
+	return "text/csv"
+}
+
+func TestCodecBody(t *testing.T) {
+	RegisterCodec(csvCodec{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set(HeaderContentType, r.Header.Get(HeaderContentType))
+		_, _ = w.Write(body)
+	}))
+	defer server.Close()
+
+	client := New()
+	tom := Person{Name: "Tom", Age: 27}
+	cases := []struct {
+		contentType         string
+		body                interface{}
+		expectedContentType string
+		expectedBody        string
+	}{
+		{body: tom, expectedContentType: ContentTypeJson, expectedBody: `{"Age":27,"Name":"Tom","Pet":{"Name":"","Color":""}}`},
+		{contentType: ContentTypeJsonUTF8, body: []int{1}, expectedContentType: ContentTypeJsonUTF8, expectedBody: `[1]`},
+		{contentType: ContentTypeXml, body: tom, expectedContentType: ContentTypeXml, expectedBody: `<Person><Age>27</Age><Name>Tom</Name><Pet><Name></Name><Color></Color></Pet></Person>`},
+		{contentType: ContentTypeForm, body: url.Values{"a": {"1", "2"}}, expectedContentType: ContentTypeForm, expectedBody: `a=1&a=2`},
+		{contentType: ContentTypeText, body: http.StatusOK, expectedContentType: ContentTypeText},
+		{contentType: "text/csv; charset=utf-8", body: []string{"a", "b"}, expectedContentType: "text/csv; charset=utf-8", expectedBody: "a,b"},
+	}
+	for _, c := range cases {
+		resp, err := client.Post(server.URL).ContentType(c.contentType).Body(c.body).Go()
+		if c.expectedBody == "" {
+			if err == nil {
+				_ = resp.Body.Close()
+				t.Errorf("%s: expected an error encoding %T", c.contentType, c.body)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(c.contentType, err)
+		}
+		body, _ := readAll(resp)
+		if ct := resp.Header.Get(HeaderContentType); ct != c.expectedContentType {
+			t.Errorf("expected content type %s, got %s", c.expectedContentType, ct)
+		}
+		if string(body) != c.expectedBody {
+			t.Errorf("%s: expected body %s, got %s", c.contentType, c.expectedBody, body)
+		}
+	}
+
+	var fields []string
+	if err := client.Post(server.URL).ContentType("text/csv").Body([]string{"x", "y"}).Decode(&fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 2 || fields[1] != "y" {
+		t.Errorf("expected fields decoded by the registered codec, got %v", fields)
+	}
+
+	if _, err := client.Post(server.URL).ContentType("application/unknown").Body(tom).Go(); err == nil {
+		t.Error("expected an error for a content type without codec")
+	}
+}
+
+func TestCodecFor(t *testing.T) {
+	cases := []struct {
+		contentType string
+		expected    string
+	}{
+		{ContentTypeJsonUTF8, ContentTypeJson},
+		{"application/problem+json", ContentTypeJson},
+		{"text/xml; charset=utf-8", ContentTypeXml},
+		{"application/atom+xml", ContentTypeXml},
+		{"Text/Plain", ContentTypeText},
+		{ContentTypeForm, ContentTypeForm},
+	}
+	for _, c := range cases {
+		codec, ok := CodecFor(c.contentType)
+		if !ok || codec.ContentType() != c.expected {
+			t.Errorf("expected codec for %s to be %s, got %v", c.contentType, c.expected, codec)
+		}
+	}
+	if _, ok := CodecFor("image/png"); ok {
+		t.Error("expected no codec for image/png")
+	}
+}
@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strings"
+)
+
+// Curl returns a curl command sending the request as Go would, with its
+// method, full url, headers and body, and the TLS files, verification and
+// pins of the client. A body read from a one-shot io.Reader is taken from
+// stdin, and the password of a PKCS#12 file is left for curl to prompt.
+func (r *Request) Curl() (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	// build the request without the one-shot bodies, so they're not consumed
+	noStream := *r
+	noStream.parts, noStream.bodyReader = nil, nil
+	req, err := noStream.makeRequest(context.Background())
+	if err != nil {
+		return "", err
+	}
+	args := []string{"curl", "-X", req.Method, req.URL.String()}
+	if req.Method == HEAD {
+		// with -X HEAD, curl waits for a body that never comes
+		args = []string{"curl", "--head", req.URL.String()}
+	}
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range req.Header[k] {
+			args = append(args, "-H", k+": "+v)
+		}
+	}
+
+	switch {
+	case len(r.parts) > 0:
+		for _, p := range r.parts {
+			switch {
+			case p.open == nil:
+				args = append(args, "--form-string", p.field+"="+p.value)
+			case p.reopenable:
+				args = append(args, "-F", p.field+"=@"+p.path+";type="+partContentType(p.filename))
+			default:
+				args = append(args, "-F", p.field+"=@"+p.filename+";type="+partContentType(p.filename))
+			}
+		}
+	case r.bodyReader != nil:
+		args = append(args, "--data-binary", "@-")
+	case req.Body != nil && req.Body != http.NoBody:
+		body, err := ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		if len(body) > 0 {
+			// unlike --data-binary, --data-raw doesn't read a file for a body starting with @
+			args = append(args, "--data-raw", string(body))
+		}
+	}
+
+	args = append(args, r.client.curlArgs...)
+	if config := r.client.tlsClientConfig(); config != nil && config.InsecureSkipVerify {
+		args = append(args, "-k")
+	}
+	if len(r.client.pins) > 0 {
+		pins := make([]string, 0, len(r.client.pins))
+		for pin := range r.client.pins {
+			pins = append(pins, "sha256//"+pin)
+		}
+		sort.Strings(pins)
+		args = append(args, "--pinnedpubkey", strings.Join(pins, ";"))
+	}
+
+	for i, arg := range args[1:] {
+		args[i+1] = shellQuote(arg)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// shellQuote quotes s for a POSIX shell, leaving it as it is if that's safe.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:@=,+%", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// DumpRequest returns the request as it would be sent on the wire,
+// including the body if body is true.
+// Bodies read from one-shot io.Readers, including File parts, are consumed
+// by the dump, so such a request can't be sent afterwards.
+func (r *Request) DumpRequest(body bool) (string, error) {
+	if r.err != nil {
+		return "", r.err
+	}
+	req, err := r.makeRequest(context.Background())
+	if err != nil {
+		return "", err
+	}
+	dump, err := httputil.DumpRequestOut(req, body)
+	if !body && req.Body != nil {
+		_ = req.Body.Close()
+	}
+	return string(dump), err
+}
+
+// DumpResponse returns resp as it was received on the wire, including
+// the body if body is true; the body is still readable afterwards.
+func DumpResponse(resp *http.Response, body bool) (string, error) {
+	dump, err := httputil.DumpResponse(resp, body)
+	return string(dump), err
+}
@@ -0,0 +1,204 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCurl(t *testing.T) {
+	client := New().BaseURL("http://example.com/api")
+	cmd, err := client.Post("/users/{id}").
+		PathParam("id", "42").
+		AppendQuery("q", "it's").
+		Header("X-Token", "a b").
+		Body(map[string]string{"name": "O'Brien"}).
+		Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = `curl -X POST 'http://example.com/api/users/42?q=it%27s' -H 'Content-Type: application/json' -H 'X-Token: a b' --data-raw '{"name":"O'\''Brien"}'`
+	if cmd != expected {
+		t.Errorf("expected curl command\n%s\ngot\n%s", expected, cmd)
+	}
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	ca := newTestCA(t)
+	_, certPEM, keyPEM := ca.issue(t)
+	files := map[string][]byte{"ca.crt": ca.certPEM, "tls.crt": certPEM, "tls.key": keyPEM}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), content, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pin := SPKISHA256(ca.cert)
+	cmd, err = New().
+		AddCAFile(filepath.Join(dir, "ca.crt")).
+		AddCertFile(filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")).
+		InsecureSkipVerify(true).
+		PinSHA256(pin).
+		Get("https://example.com").
+		Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, arg := range []string{
+		"--cacert " + filepath.Join(dir, "ca.crt"),
+		"--cert " + filepath.Join(dir, "tls.crt"),
+		"--key " + filepath.Join(dir, "tls.key"),
+		" -k",
+		"--pinnedpubkey " + shellQuote("sha256//"+pin),
+	} {
+		if !strings.Contains(cmd, arg) {
+			t.Errorf("expected %s in %s", arg, cmd)
+		}
+	}
+	if strings.Contains(cmd, "--data") {
+		t.Errorf("expected no body for a GET, got %s", cmd)
+	}
+
+	cmd, err = New().Post("http://example.com").Body("@/etc/passwd").Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasSuffix(cmd, "--data-raw @/etc/passwd") {
+		t.Errorf("expected a body starting with @ sent as it is, got %s", cmd)
+	}
+
+	cmd, err = New().Head("http://example.com").Curl()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd != "curl --head http://example.com" {
+		t.Errorf("expected a HEAD request made with --head, got %s", cmd)
+	}
+}
+
+func TestCurlRuns(t *testing.T) {
+	if _, err := exec.LookPath("curl"); err != nil {
+		t.Skip("curl not found")
+	}
+	type received struct {
+		Method string
+		Query  string
+		Header http.Header
+		Body   string
+		Form   map[string][]string
+	}
+	receive := func(r *http.Request) received {
+		got := received{Method: r.Method, Query: r.URL.RawQuery, Header: r.Header}
+		if strings.HasPrefix(r.Header.Get(HeaderContentType), ContentTypeMultipart) {
+			_ = r.ParseMultipartForm(1 << 20)
+			got.Form = r.MultipartForm.Value
+			for field, files := range r.MultipartForm.File {
+				file, _ := files[0].Open()
+				content, _ := ioutil.ReadAll(file)
+				got.Form[field] = []string{files[0].Filename, string(content)}
+			}
+		} else {
+			body, _ := ioutil.ReadAll(r.Body)
+			got.Body = string(body)
+		}
+		return got
+	}
+	// the request sent by curl, received in the server goroutine
+	curlReceived := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-From") == "curl" {
+			curlReceived <- receive(r)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(receive(r))
+	}))
+	defer server.Close()
+
+	path := filepath.Join("testdata", "ca.crt")
+	requests := []func() *Request{
+		func() *Request {
+			return New().Put(server.URL+"/a b").AddQuery("tag", "a&b").AddQuery("tag", "it's").Body(Person{Name: "O'Brien $HOME"})
+		},
+		func() *Request {
+			return New().Post(server.URL).Form(map[string][]string{"k": {"v 1", "'v2'"}})
+		},
+		func() *Request {
+			return New().Post(server.URL).Field("name", "@Tom;x").FileFromPath("ca", path)
+		},
+		func() *Request {
+			return New().Post(server.URL).ContentType(ContentTypeText).Body("@" + path)
+		},
+	}
+	for _, build := range requests {
+		cmd, err := build().Header("X-From", "curl").Curl()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out, err := exec.Command("sh", "-c", cmd+" -s -S").CombinedOutput(); err != nil {
+			t.Fatalf("%s failed: %v\n%s", cmd, err, out)
+		}
+		fromCurl := <-curlReceived
+		var fromGo received
+		if err = build().ToJSON(&fromGo); err != nil {
+			t.Fatal(err)
+		}
+		if fromCurl.Method != fromGo.Method || fromCurl.Query != fromGo.Query || fromCurl.Body != fromGo.Body {
+			t.Errorf("%s\nsent %+v, expected %+v", cmd, fromCurl, fromGo)
+		}
+		if fromCurl.Header.Get(HeaderContentType) != fromGo.Header.Get(HeaderContentType) && fromGo.Form == nil {
+			t.Errorf("%s\nsent content type %s, expected %s", cmd, fromCurl.Header.Get(HeaderContentType), fromGo.Header.Get(HeaderContentType))
+		}
+		for k, v := range fromGo.Form {
+			if strings.Join(fromCurl.Form[k], "|") != strings.Join(v, "|") {
+				t.Errorf("%s\nsent form field %s=%v, expected %v", cmd, k, fromCurl.Form[k], v)
+			}
+		}
+	}
+}
+
+func TestDump(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Reply", "yes")
+		_, _ = w.Write([]byte("Hello world!"))
+	}))
+	defer server.Close()
+
+	request := New().Post(server.URL+"/dump").Header("X-Key", "value").Body("request body")
+	dump, err := request.DumpRequest(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"POST /dump HTTP/1.1\r\n", "X-Key: value\r\n", "\r\n\r\nrequest body"} {
+		if !strings.Contains(dump, s) {
+			t.Errorf("expected %q in request dump:\n%s", s, dump)
+		}
+	}
+
+	resp, err := request.Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dump, err = DumpResponse(resp, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"HTTP/1.1 200 OK\r\n", "X-Reply: yes\r\n", "\r\n\r\nHello world!"} {
+		if !strings.Contains(dump, s) {
+			t.Errorf("expected %q in response dump:\n%s", s, dump)
+		}
+	}
+	body, _ := readAll(resp)
+	if string(body) != "Hello world!" {
+		t.Errorf("expected the response body to be readable after the dump, got %q", body)
+	}
+}
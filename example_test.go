@@ -115,26 +115,13 @@ func addPerson(url string, p Person) {
 	})
 }
 func queryPersons(url string) {
-	New().Get(url).Do(func(response *http.Response, err error) {
-		if err != nil {
-			fmt.Println("query persons failed, status code:", err)
-			return
-		}
-		defer func() {
-			_ = response.Body.Close()
-		}()
-		if response.StatusCode != http.StatusOK {
-			fmt.Println("query persons status is:", response.Status)
-		}
-		var quariedPersons []Person
-		body, _ := ioutil.ReadAll(response.Body)
-		err = json.Unmarshal(body, &quariedPersons)
-		if err != nil {
-			fmt.Println("unmarshal resonse as a person array failed:", err)
-			return
-		}
-		fmt.Println("quiried persons:", quariedPersons)
-	})
+	var quariedPersons []Person
+	err := New().Get(url).ToJSON(&quariedPersons)
+	if err != nil {
+		fmt.Println("query persons failed:", err)
+		return
+	}
+	fmt.Println("quiried persons:", quariedPersons)
 }
 
 func modifyPersonAge(url string, p Person, age int) {
@@ -182,7 +169,7 @@ f9Oeos0UUothgiDktdQHxdNEwLjQf7lJJBzV+5OtwswCWA==
 -----END RSA PRIVATE KEY-----`)
 )
 
-func ExampleTlsConfig() {
+func ExampleClient_TlsConfig() {
 	const url = "/test"
 	const body = "Hello world!"
 
@@ -196,8 +183,8 @@ func ExampleTlsConfig() {
 	New().
 		AddCAContent(testCAContent).
 		AddCertContent(testCertContent, testKeyContent).
-		Get(server.URL + url).
 		InsecureSkipVerify(true).
+		Get(server.URL + url).
 		Do(func(response *http.Response, err error) {
 			if err != nil {
 				fmt.Println(err)
@@ -212,7 +199,7 @@ func ExampleTlsConfig() {
 	// Hello world!
 }
 
-func ExampleQuery() {
+func ExampleRequest_AppendQueries() {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		_, _ = w.Write([]byte(r.URL.RawQuery))
 	}))
@@ -0,0 +1,173 @@
+package httpclient
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ContentTypeMultipart   = "multipart/form-data"
+	ContentTypeOctetStream = "application/octet-stream"
+)
+
+// part is a field or a file of a multipart/form-data body.
+type part struct {
+	field    string
+	value    string
+	filename string
+	// path is the file of a part added by FileFromPath
+	path string
+	// open returns the content of a file part, nil for a plain field
+	open func() (io.ReadCloser, error)
+	// reopenable reports whether open can be called more than once
+	reopenable bool
+}
+
+// Form adds values to an application/x-www-form-urlencoded body.
+// A form body replaces the one set by Body, and Content-Type defaults to ContentTypeForm.
+func (r *Request) Form(values url.Values) *Request {
+	for k, vs := range values {
+		for _, v := range vs {
+			r.FormField(k, v)
+		}
+	}
+	return r
+}
+
+// FormField adds the value to key in an application/x-www-form-urlencoded body, see Form.
+func (r *Request) FormField(key, value string) *Request {
+	if r.form == nil {
+		r.form = make(url.Values)
+	}
+	r.form.Add(key, value)
+	return r
+}
+
+// Field adds a plain field to a multipart/form-data body.
+// A multipart body replaces the one set by Body or Form, and is streamed
+// to the server part by part.
+func (r *Request) Field(field, value string) *Request {
+	r.parts = append(r.parts, &part{field: field, value: value})
+	return r
+}
+
+// File adds a file part read from reader to a multipart/form-data body, see Field.
+// The part content type is guessed from the filename extension.
+// If reader is an io.Closer it's closed once the part is written.
+// A request with such a part can't be replayed, so it's never retried.
+func (r *Request) File(field, filename string, reader io.Reader) *Request {
+	if reader == nil {
+		r.keepOriginErr(errors.New("invalid file, reader is nil"))
+		return r
+	}
+	r.parts = append(r.parts, &part{
+		field:    field,
+		filename: filename,
+		open: func() (io.ReadCloser, error) {
+			if rc, ok := reader.(io.ReadCloser); ok {
+				return rc, nil
+			}
+			return ioutil.NopCloser(reader), nil
+		},
+	})
+	return r
+}
+
+// FileFromPath adds the file at path to a multipart/form-data body, see Field.
+// The file is opened only when the request is sent.
+func (r *Request) FileFromPath(field, path string) *Request {
+	if _, err := os.Stat(path); err != nil {
+		r.keepOriginErr(err)
+		return r
+	}
+	r.parts = append(r.parts, &part{
+		field:    field,
+		filename: filepath.Base(path),
+		path:     path,
+		open: func() (io.ReadCloser, error) {
+			return os.Open(path)
+		},
+		reopenable: true,
+	})
+	return r
+}
+
+// multipartBody returns a reader streaming the multipart parts of r,
+// and the content type with its boundary.
+// The boundary is generated once, so that the body matches the content
+// type when it's reread, such as on redirects.
+func (r *Request) multipartBody() (io.ReadCloser, string) {
+	pr, pw := io.Pipe()
+	writer := multipart.NewWriter(pw)
+	if r.boundary == "" {
+		r.boundary = writer.Boundary()
+	} else if err := writer.SetBoundary(r.boundary); err != nil {
+		_ = pw.CloseWithError(err)
+		return pr, writer.FormDataContentType()
+	}
+	go func() {
+		for _, p := range r.parts {
+			if err := writePart(writer, p); err != nil {
+				_ = pw.CloseWithError(err)
+				return
+			}
+		}
+		_ = pw.CloseWithError(writer.Close())
+	}()
+	return pr, writer.FormDataContentType()
+}
+
+func writePart(writer *multipart.Writer, p *part) error {
+	if p.open == nil {
+		return writer.WriteField(p.field, p.value)
+	}
+	content, err := p.open()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = content.Close()
+	}()
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+		escapeQuotes(p.field), escapeQuotes(p.filename)))
+	header.Set(HeaderContentType, partContentType(p.filename))
+	w, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, content)
+	return err
+}
+
+func partContentType(filename string) string {
+	if contentType := mime.TypeByExtension(filepath.Ext(filename)); contentType != "" {
+		return contentType
+	}
+	return ContentTypeOctetStream
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// multipartReplayable reports whether every part can be read again.
+func (r *Request) multipartReplayable() bool {
+	for _, p := range r.parts {
+		if p.open != nil && !p.reopenable {
+			return false
+		}
+	}
+	return true
+}
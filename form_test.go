@@ -0,0 +1,130 @@
+package httpclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForm(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(HeaderContentType) != ContentTypeForm {
+			t.Errorf("expected content type %s, got %s", ContentTypeForm, r.Header.Get(HeaderContentType))
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		_, _ = w.Write([]byte(r.PostForm.Encode()))
+	}))
+	defer server.Close()
+
+	var s string
+	err := New().Post(server.URL).
+		Form(url.Values{"tag": {"a", "b"}}).
+		FormField("name", "Tom & Joe").
+		ToString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const expected = "name=Tom+%26+Joe&tag=a&tag=b"
+	if s != expected {
+		t.Errorf("expected form %s, got %s", expected, s)
+	}
+}
+
+func TestMultipart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "report.json")
+	if err = ioutil.WriteFile(path, []byte(`{"ok":true}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TransferEncoding) == 0 || r.TransferEncoding[0] != "chunked" {
+			t.Errorf("expected a chunked multipart body, got transfer encoding %v", r.TransferEncoding)
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatal(err)
+		}
+		if v := r.FormValue("name"); v != "Tom" {
+			t.Errorf("expected field name=Tom, got %s", v)
+		}
+		files := []struct {
+			field, filename, contentType, content string
+		}{
+			{"note", "note.txt", "text/plain; charset=utf-8", "Hello world"},
+			{"report", "report.json", "application/json", `{"ok":true}`},
+			{"blob", "blob", ContentTypeOctetStream, "\x00\x01"},
+		}
+		for _, f := range files {
+			file, header, err := r.FormFile(f.field)
+			if err != nil {
+				t.Fatal(f.field, err)
+			}
+			content, _ := ioutil.ReadAll(file)
+			_ = file.Close()
+			if header.Filename != f.filename {
+				t.Errorf("expected filename %s, got %s", f.filename, header.Filename)
+			}
+			if ct := header.Header.Get(HeaderContentType); ct != f.contentType {
+				t.Errorf("%s: expected content type %s, got %s", f.field, f.contentType, ct)
+			}
+			if string(content) != f.content {
+				t.Errorf("%s: expected content %q, got %q", f.field, f.content, content)
+			}
+		}
+	}))
+	defer server.Close()
+
+	resp, err := New().Post(server.URL).
+		Field("name", "Tom").
+		File("note", "note.txt", strings.NewReader("Hello world")).
+		FileFromPath("report", path).
+		File("blob", "blob", strings.NewReader("\x00\x01")).
+		ExpectOK().
+		Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	if _, err = New().Post(server.URL).FileFromPath("missing", filepath.Join(dir, "missing")).Go(); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+func TestMultipartRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/r" {
+			http.Redirect(w, r, "/target", http.StatusTemporaryRedirect)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(r.FormValue("name")))
+	}))
+	defer server.Close()
+
+	var s string
+	err := New().Post(server.URL+"/r").Field("name", "Tom").FileFromPath("ca", filepath.Join("testdata", "ca.crt")).
+		ExpectOK().ToString(&s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s != "Tom" {
+		t.Errorf("expected Tom, got %q", s)
+	}
+}
@@ -0,0 +1,481 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultHARBodyLimit is the max number of body bytes captured by a
+// HARRecorder when HARRecorder.BodyLimit is 0.
+const DefaultHARBodyLimit = 64 << 10
+
+// HAR is an HTTP Archive 1.2 document, which browser devtools can import.
+// See http://www.softwareishard.com/blog/har-12-spec/.
+type HAR struct {
+	Log HARLog `json:"log"`
+}
+
+type HARLog struct {
+	Version string      `json:"version"`
+	Creator HARCreator  `json:"creator"`
+	Entries []*HAREntry `json:"entries"`
+}
+
+type HARCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// HAREntry is a request and its response. A failed request has a zero
+// response, and its error in the custom _error field.
+type HAREntry struct {
+	StartedDateTime time.Time   `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         HARRequest  `json:"request"`
+	Response        HARResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         HARTimings  `json:"timings"`
+	ServerIPAddress string      `json:"serverIPAddress,omitempty"`
+	Connection      string      `json:"connection,omitempty"`
+	Error           string      `json:"_error,omitempty"`
+}
+
+type HARRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	QueryString []HARNameValue `json:"queryString"`
+	PostData    *HARPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []HARCookie    `json:"cookies"`
+	Headers     []HARNameValue `json:"headers"`
+	Content     HARContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type HARNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type HARCookie struct {
+	Name     string     `json:"name"`
+	Value    string     `json:"value"`
+	Path     string     `json:"path,omitempty"`
+	Domain   string     `json:"domain,omitempty"`
+	Expires  *time.Time `json:"expires,omitempty"`
+	HTTPOnly bool       `json:"httpOnly,omitempty"`
+	Secure   bool       `json:"secure,omitempty"`
+}
+
+type HARPostData struct {
+	MimeType string         `json:"mimeType"`
+	Params   []HARNameValue `json:"params,omitempty"`
+	Text     string         `json:"text"`
+}
+
+// HARContent is a response body. Text is cut to HARRecorder.BodyLimit bytes,
+// and base64 encoded if it isn't valid UTF-8; Size is the full body size.
+type HARContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+}
+
+// HARTimings are the durations of the phases of a request in milliseconds,
+// -1 for the phases that didn't happen, such as dns and connect when the
+// connection is reused. Connect includes ssl.
+type HARTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+// HARRecorder captures the requests sent through its Middleware as HAR
+// entries, with their timings, headers, cookies, queries and bodies:
+//
+//	har := httpclient.NewHARRecorder()
+//	client := httpclient.New().UseTransport(har.Middleware)
+//	...
+//	err := har.WriteFile("traffic.har")
+//
+// With Client.UseTransport, each exchange of a redirect chain is an entry,
+// as HAR expects; with Client.Use, a redirect chain would be collapsed
+// into one entry, mixing the request of the first exchange with the
+// response of the last one.
+// An entry is added when its response headers are received, and its
+// response body is captured as it's read by the caller.
+// Request bodies are captured only if they can be reread, see Request.BodyFunc.
+type HARRecorder struct {
+	// BodyLimit is the max number of request and response body bytes
+	// captured, 0 means DefaultHARBodyLimit, negative captures no body.
+	BodyLimit int
+	// RedactHeaders are redacted in addition to DefaultRedactedHeaders,
+	// which also redacts the values of cookies.
+	RedactHeaders []string
+	// RedactQueries are the redacted query parameters,
+	// nil means DefaultRedactedQueries.
+	RedactQueries []string
+
+	mu      sync.Mutex
+	entries []*HAREntry
+}
+
+func NewHARRecorder() *HARRecorder {
+	return &HARRecorder{}
+}
+
+// Middleware captures the requests sent through it, see Client.UseTransport.
+func (h *HARRecorder) Middleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		timer := newTracer()
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), timer.clientTrace()))
+		entry := &HAREntry{
+			StartedDateTime: timer.start,
+			Request:         h.harRequest(req),
+		}
+		resp, err := next(req)
+		timer.done()
+		if err != nil {
+			entry.Error = err.Error()
+			entry.Response = HARResponse{
+				Cookies:     []HARCookie{},
+				Headers:     []HARNameValue{},
+				HeadersSize: -1,
+				BodySize:    -1,
+			}
+			h.add(entry, timer)
+			return nil, err
+		}
+		entry.Response = h.harResponse(resp)
+		h.add(entry, timer)
+		resp.Body = &harBody{
+			ReadCloser:   resp.Body,
+			recorder:     h,
+			entry:        entry,
+			timer:        timer,
+			limit:        h.bodyLimit(),
+			uncompressed: resp.Uncompressed,
+		}
+		return resp, nil
+	}
+}
+
+// HAR returns a copy of the entries captured so far, as a HAR document.
+func (h *HARRecorder) HAR() *HAR {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	entries := make([]*HAREntry, len(h.entries))
+	for i, e := range h.entries {
+		entry := *e
+		entries[i] = &entry
+	}
+	return &HAR{Log: HARLog{
+		Version: "1.2",
+		Creator: HARCreator{Name: "httpclient", Version: "1.0"},
+		Entries: entries,
+	}}
+}
+
+// WriteTo writes the entries captured so far to w as a HAR document.
+func (h *HARRecorder) WriteTo(w io.Writer) (int64, error) {
+	content, err := json.MarshalIndent(h.HAR(), "", "  ")
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(content)
+	return int64(n), err
+}
+
+// WriteFile writes the entries captured so far to the HAR file at path.
+func (h *HARRecorder) WriteFile(path string) error {
+	var buf bytes.Buffer
+	if _, err := h.WriteTo(&buf); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, buf.Bytes(), 0644)
+}
+
+// Reset drops the entries captured so far.
+func (h *HARRecorder) Reset() {
+	h.mu.Lock()
+	h.entries = nil
+	h.mu.Unlock()
+}
+
+func (h *HARRecorder) add(entry *HAREntry, timer *tracer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	timer.harTimings(entry, time.Time{})
+	h.entries = append(h.entries, entry)
+}
+
+func (h *HARRecorder) bodyLimit() int {
+	if h.BodyLimit == 0 {
+		return DefaultHARBodyLimit
+	}
+	return h.BodyLimit
+}
+
+func (h *HARRecorder) logOptions() LogOptions {
+	return LogOptions{RedactHeaders: h.RedactHeaders, RedactQueries: h.RedactQueries}
+}
+
+func (h *HARRecorder) harRequest(req *http.Request) HARRequest {
+	opts := h.logOptions()
+	u, _ := url.Parse(redactUrl(req.URL, opts.redactedQueries()))
+	r := HARRequest{
+		Method:      req.Method,
+		URL:         u.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(opts.redactHeader(req.Header)),
+		QueryString: harParams(u.RawQuery),
+		HeadersSize: -1,
+		BodySize:    req.ContentLength,
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		r.BodySize = 0
+		return r
+	}
+	contentType := req.Header.Get(HeaderContentType)
+	r.PostData = &HARPostData{MimeType: contentType}
+	if limit := h.bodyLimit(); limit > 0 && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			r.PostData.Text = readLimit(body, limit)
+			_ = body.Close()
+		}
+	}
+	if mediaType(contentType) == ContentTypeForm && r.PostData.Text != "" {
+		r.PostData.Params = harParams(r.PostData.Text)
+	}
+	return r
+}
+
+func (h *HARRecorder) harResponse(resp *http.Response) HARResponse {
+	bodySize := resp.ContentLength
+	if resp.Uncompressed {
+		// the transferred size of a body decompressed by the transport is unknown
+		bodySize = -1
+	}
+	location := resp.Header.Get("Location")
+	if u, err := resp.Location(); err == nil {
+		location = u.String()
+	}
+	return HARResponse{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Cookies:     harCookies(resp.Cookies()),
+		Headers:     harHeaders(h.logOptions().redactHeader(resp.Header)),
+		Content:     HARContent{MimeType: resp.Header.Get(HeaderContentType)},
+		RedirectURL: location,
+		HeadersSize: -1,
+		BodySize:    bodySize,
+	}
+}
+
+func harHeaders(header http.Header) []HARNameValue {
+	values := []HARNameValue{}
+	for k, vs := range header {
+		for _, v := range vs {
+			values = append(values, HARNameValue{Name: k, Value: v})
+		}
+	}
+	return values
+}
+
+// harParams returns the parameters of an url encoded query, in their order.
+func harParams(query string) []HARNameValue {
+	values := []HARNameValue{}
+	if query == "" {
+		return values
+	}
+	for _, pair := range strings.Split(query, "&") {
+		k, v := pair, ""
+		if i := strings.Index(pair, "="); i >= 0 {
+			k, v = pair[:i], pair[i+1:]
+		}
+		if unescaped, err := url.QueryUnescape(k); err == nil {
+			k = unescaped
+		}
+		if unescaped, err := url.QueryUnescape(v); err == nil {
+			v = unescaped
+		}
+		values = append(values, HARNameValue{Name: k, Value: v})
+	}
+	return values
+}
+
+// harCookies returns cookies with their values redacted, like the Cookie
+// and Set-Cookie headers.
+func harCookies(cookies []*http.Cookie) []HARCookie {
+	values := []HARCookie{}
+	for _, c := range cookies {
+		cookie := HARCookie{
+			Name:     c.Name,
+			Value:    Redacted,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+		}
+		if !c.Expires.IsZero() {
+			expires := c.Expires
+			cookie.Expires = &expires
+		}
+		values = append(values, cookie)
+	}
+	return values
+}
+
+// harTimings sets the timings of entry, with the response body read
+// at bodyDone, zero if it isn't yet. Phases missing from the trace, such as
+// with a transport that doesn't dial, are counted as blocked or waiting.
+func (t *tracer) harTimings(entry *HAREntry, bodyDone time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timings := HARTimings{DNS: -1, Connect: -1, SSL: -1}
+	if !t.dnsDone.IsZero() {
+		timings.DNS = millis(t.dnsStart, t.dnsDone)
+	}
+	if !t.connectDone.IsZero() {
+		connectDone := t.connectDone
+		if t.tlsDone.After(connectDone) {
+			connectDone = t.tlsDone
+		}
+		timings.Connect = millis(t.connectStart, connectDone)
+	}
+	if !t.tlsDone.IsZero() {
+		timings.SSL = millis(t.tlsStart, t.tlsDone)
+	}
+	gotConn := orTime(t.gotConn, t.start)
+	wroteRequest := orTime(t.wroteRequest, gotConn)
+	firstByte := orTime(t.firstByte, t.end)
+	timings.Blocked = millis(t.start, gotConn) - positive(timings.DNS) - positive(timings.Connect)
+	if timings.Blocked < 0 {
+		timings.Blocked = 0
+	}
+	timings.Send = millis(gotConn, wroteRequest)
+	timings.Wait = millis(wroteRequest, firstByte)
+	if !bodyDone.IsZero() {
+		timings.Receive = millis(firstByte, bodyDone)
+	}
+	entry.Timings = timings
+	entry.Time = timings.Blocked + positive(timings.DNS) + positive(timings.Connect) +
+		timings.Send + timings.Wait + timings.Receive
+	if t.remoteAddr != nil {
+		if host, _, err := net.SplitHostPort(t.remoteAddr.String()); err == nil {
+			entry.ServerIPAddress = host
+		}
+	}
+	if t.localAddr != nil {
+		if _, port, err := net.SplitHostPort(t.localAddr.String()); err == nil {
+			entry.Connection = port
+		}
+	}
+}
+
+func millis(from, to time.Time) float64 {
+	if to.Before(from) {
+		return 0
+	}
+	return float64(to.Sub(from)) / float64(time.Millisecond)
+}
+
+func positive(ms float64) float64 {
+	if ms < 0 {
+		return 0
+	}
+	return ms
+}
+
+func orTime(t, fallback time.Time) time.Time {
+	if t.IsZero() {
+		return fallback
+	}
+	return t
+}
+
+// harBody captures a response body into its entry as it's read.
+type harBody struct {
+	io.ReadCloser
+	recorder *HARRecorder
+	entry    *HAREntry
+	timer    *tracer
+	limit    int
+	// uncompressed tells the body was decompressed by the transport
+	uncompressed bool
+	buf          bytes.Buffer
+	size         int64
+	once         sync.Once
+}
+
+func (b *harBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.size += int64(n)
+	if room := b.limit - b.buf.Len(); room > 0 {
+		if room > n {
+			room = n
+		}
+		b.buf.Write(p[:room])
+	}
+	if err == io.EOF {
+		b.finish()
+	}
+	return n, err
+}
+
+func (b *harBody) Close() error {
+	b.finish()
+	return b.ReadCloser.Close()
+}
+
+// finish sets the content and the receive timing of the entry, once the
+// body is read or closed.
+func (b *harBody) finish() {
+	b.once.Do(func() {
+		bodyDone := time.Now()
+		b.recorder.mu.Lock()
+		defer b.recorder.mu.Unlock()
+		content := &b.entry.Response.Content
+		content.Size = b.size
+		if utf8.Valid(b.buf.Bytes()) {
+			content.Text = b.buf.String()
+		} else {
+			content.Text = base64.StdEncoding.EncodeToString(b.buf.Bytes())
+			content.Encoding = "base64"
+		}
+		if b.entry.Response.BodySize < 0 && !b.uncompressed {
+			b.entry.Response.BodySize = b.size
+		}
+		b.timer.harTimings(b.entry, bodyDone)
+	})
+}
@@ -0,0 +1,208 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHARRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/", HttpOnly: true})
+		w.Header().Set(HeaderContentType, ContentTypeText)
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	har := NewHARRecorder()
+	client := New().UseTransport(har.Middleware)
+	form := map[string][]string{"name": {"Alice"}}
+	for i := 0; i < 2; i++ {
+		var s string
+		err := client.Post(server.URL+"/users").SetQuery("q", "a b").SetQuery("token", "t").
+			Header("Cookie", "id=1").Form(form).ToString(&s)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries := har.HAR().Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	entry := entries[0]
+	req := entry.Request
+	if req.Method != POST || !strings.HasSuffix(req.URL, "/users?q=a+b&token="+Redacted) {
+		t.Errorf("unexpected request %s %s", req.Method, req.URL)
+	}
+	expectedQueries := []HARNameValue{{"q", "a b"}, {"token", Redacted}}
+	if !harValuesEqual(req.QueryString, expectedQueries) {
+		t.Errorf("expected queries %v, got %v", expectedQueries, req.QueryString)
+	}
+	if len(req.Cookies) != 1 || req.Cookies[0].Name != "id" || req.Cookies[0].Value != Redacted {
+		t.Errorf("unexpected request cookies %v", req.Cookies)
+	}
+	if req.PostData == nil || req.PostData.Text != "name=Alice" ||
+		!harValuesEqual(req.PostData.Params, []HARNameValue{{"name", "Alice"}}) {
+		t.Errorf("unexpected post data %+v", req.PostData)
+	}
+
+	resp := entry.Response
+	if resp.Status != http.StatusOK || resp.Content.Text != "hello" || resp.Content.Size != 5 || resp.BodySize != 5 {
+		t.Errorf("unexpected response %+v", resp)
+	}
+	if len(resp.Cookies) != 1 || resp.Cookies[0].Name != "session" || resp.Cookies[0].Value != Redacted || !resp.Cookies[0].HTTPOnly {
+		t.Errorf("unexpected response cookies %v", resp.Cookies)
+	}
+	for _, h := range resp.Headers {
+		if h.Name == "Set-Cookie" && h.Value != Redacted {
+			t.Errorf("expected Set-Cookie redacted, got %q", h.Value)
+		}
+	}
+
+	if entry.Timings.DNS != -1 || entry.Timings.Connect < 0 || entry.Timings.SSL != -1 {
+		t.Errorf("unexpected timings of a new connection %+v", entry.Timings)
+	}
+	if entries[1].Timings.Connect != -1 {
+		t.Errorf("expected no connect on a reused connection, got %+v", entries[1].Timings)
+	}
+	if entry.Time <= 0 || entry.ServerIPAddress != "127.0.0.1" || entry.Connection == "" {
+		t.Errorf("unexpected entry time %v, address %q, connection %q", entry.Time, entry.ServerIPAddress, entry.Connection)
+	}
+}
+
+func TestHARRecorderRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusFound)
+			return
+		}
+		_, _ = w.Write([]byte("new"))
+	}))
+	defer server.Close()
+
+	har := NewHARRecorder()
+	drain(New().UseTransport(har.Middleware).Get(server.URL + "/old").Go())
+
+	entries := har.HAR().Log.Entries
+	if len(entries) != 2 {
+		t.Fatalf("expected an entry per exchange, got %d", len(entries))
+	}
+	first, second := entries[0], entries[1]
+	if first.Request.URL != server.URL+"/old" || first.Response.Status != http.StatusFound ||
+		first.Response.RedirectURL != server.URL+"/new" {
+		t.Errorf("unexpected redirect entry %s %d %s", first.Request.URL, first.Response.Status, first.Response.RedirectURL)
+	}
+	if second.Request.URL != server.URL+"/new" || second.Response.Status != http.StatusOK || second.Response.Content.Text != "new" {
+		t.Errorf("unexpected final entry %s %d %q", second.Request.URL, second.Response.Status, second.Response.Content.Text)
+	}
+}
+
+func TestHARRecorderTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestTLSServer(t, ca)
+	defer server.Close()
+
+	har := NewHARRecorder()
+	client := New().UseTransport(har.Middleware).CertPool(ca.tlsConfig().RootCAs)
+	drain(client.Get(server.URL).Go())
+
+	timings := har.HAR().Log.Entries[0].Timings
+	if timings.SSL < 0 || timings.Connect < timings.SSL {
+		t.Errorf("expected ssl timings included in connect, got %+v", timings)
+	}
+}
+
+func TestHARRecorderBodies(t *testing.T) {
+	binary := []byte{0xff, 0xfe, 0x00, 0x01}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/binary" {
+			_, _ = w.Write(binary)
+			return
+		}
+		_, _ = w.Write([]byte(strings.Repeat("a", 100)))
+	}))
+	defer server.Close()
+
+	har := &HARRecorder{BodyLimit: 10}
+	client := New().UseTransport(har.Middleware)
+	drain(client.Post(server.URL).Body(strings.Repeat("b", 100)).Go())
+	drain(client.Get(server.URL + "/binary").Go())
+
+	entries := har.HAR().Log.Entries
+	if text := entries[0].Request.PostData.Text; text != strings.Repeat("b", 10) {
+		t.Errorf("expected the request body cut, got %q", text)
+	}
+	if content := entries[0].Response.Content; content.Text != strings.Repeat("a", 10) || content.Size != 100 {
+		t.Errorf("expected the response body cut, got %+v", content)
+	}
+	if content := entries[1].Response.Content; content.Text != "//4AAQ==" || content.Encoding != "base64" {
+		t.Errorf("expected the binary body base64 encoded, got %+v", content)
+	}
+}
+
+func TestHARRecorderError(t *testing.T) {
+	har := NewHARRecorder()
+	errAbort := errors.New("abort")
+	client := New().Use(har.Middleware, func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, errAbort
+		}
+	})
+	if _, err := client.Get("http://127.0.0.1:1").Go(); !errors.Is(err, errAbort) {
+		t.Fatalf("expected %v, got %v", errAbort, err)
+	}
+
+	dir, err := ioutil.TempDir("", "har")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "traffic.har")
+	if err = har.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Log struct {
+			Version string
+			Entries []map[string]interface{}
+		}
+	}
+	if err = json.Unmarshal(content, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Log.Version != "1.2" || len(doc.Log.Entries) != 1 || doc.Log.Entries[0]["_error"] != "abort" {
+		t.Errorf("unexpected HAR %s", content)
+	}
+	response := doc.Log.Entries[0]["response"].(map[string]interface{})
+	if response["headers"] == nil || response["cookies"] == nil {
+		t.Errorf("expected empty headers and cookies, got %v", response)
+	}
+
+	har.Reset()
+	if entries := har.HAR().Log.Entries; len(entries) != 0 {
+		t.Errorf("expected no entries after Reset, got %d", len(entries))
+	}
+}
+
+func harValuesEqual(a, b []HARNameValue) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,346 @@
+// Package httpclienttest provides a mock transport for testing code that
+// sends requests with httpclient, without starting a server.
+package httpclienttest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zrcoder/httpclient"
+)
+
+// TestingT is the subset of *testing.T used by MockTransport.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Cleanup(func())
+}
+
+// MockTransport is an http.RoundTripper replying to the requests registered
+// with On. When the test ends, it fails the test if an expectation wasn't
+// met or an unexpected request was sent.
+type MockTransport struct {
+	t            TestingT
+	mu           sync.Mutex
+	expectations []*Expectation
+	unexpected   []string
+}
+
+// NewMockTransport returns a MockTransport verified when t ends.
+func NewMockTransport(t TestingT) *MockTransport {
+	m := &MockTransport{t: t}
+	t.Cleanup(m.AssertExpectations)
+	return m
+}
+
+// Client returns a new httpclient.Client sending its requests to m.
+func (m *MockTransport) Client() *httpclient.Client {
+	return httpclient.New().Transport(m)
+}
+
+// On registers an expected request with method and a path pattern, where
+// {name} matches a single path segment, such as "/users/{id}".
+// Expectations are matched in the order they're registered.
+func (m *MockTransport) On(method, pattern string) *Expectation {
+	e := &Expectation{
+		m:       m,
+		method:  method,
+		pattern: splitPath(pattern),
+		query:   make(map[string]string),
+		header:  make(http.Header),
+	}
+	m.mu.Lock()
+	m.expectations = append(m.expectations, e)
+	m.mu.Unlock()
+	return e
+}
+
+// RoundTrip replies to req with the first matching expectation that wasn't
+// exhausted, or fails the test if there is none.
+func (m *MockTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	var (
+		matched *Expectation
+		params  map[string]string
+	)
+	for _, e := range m.expectations {
+		if params = e.match(req, body); params != nil && !e.exhausted() {
+			matched = e
+			break
+		}
+	}
+	if matched == nil {
+		call := req.Method + " " + req.URL.String()
+		m.unexpected = append(m.unexpected, call)
+		m.mu.Unlock()
+		m.t.Helper()
+		m.t.Errorf("unexpected request %s", call)
+		return nil, fmt.Errorf("httpclienttest: unexpected request %s", call)
+	}
+	step := matched.next(&Call{Request: req, Body: body, Params: params})
+	m.mu.Unlock()
+
+	if step.delay > 0 {
+		timer := time.NewTimer(step.delay)
+		defer timer.Stop()
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+	if step.err != nil {
+		return nil, step.err
+	}
+	return step.response(req), nil
+}
+
+// AssertExpectations fails the test if an expectation wasn't called the
+// expected number of times, or an unexpected request was sent.
+// It's called when the test ends.
+func (m *MockTransport) AssertExpectations() {
+	m.t.Helper()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, e := range m.expectations {
+		if e.times >= 0 && len(e.calls) != e.expectedCalls() {
+			m.t.Errorf("expected %s to be called %d times, got %d", e, e.expectedCalls(), len(e.calls))
+		}
+	}
+	if len(m.unexpected) > 0 {
+		m.t.Errorf("unexpected requests: %s", strings.Join(m.unexpected, ", "))
+	}
+}
+
+// Call is a request received by an expectation.
+type Call struct {
+	Request *http.Request
+	Body    []byte
+	// Params are the values of the {name} segments of the pattern.
+	Params map[string]string
+}
+
+// Expectation is an expected request, and the replies to it.
+type Expectation struct {
+	m       *MockTransport
+	method  string
+	pattern []string
+	query   map[string]string
+	header  http.Header
+	body    *string
+	steps   []*step
+	// times is the expected number of calls, 0 means one per reply
+	// and -1 any number
+	times int
+	calls []*Call
+}
+
+// step is the reply to a call.
+type step struct {
+	status int
+	header http.Header
+	body   []byte
+	err    error
+	delay  time.Duration
+}
+
+func (s *step) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", s.status, http.StatusText(s.status)),
+		StatusCode:    s.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        s.header.Clone(),
+		Body:          ioutil.NopCloser(bytes.NewReader(s.body)),
+		ContentLength: int64(len(s.body)),
+		Request:       req,
+	}
+}
+
+func (e *Expectation) String() string {
+	return e.method + " /" + strings.Join(e.pattern, "/")
+}
+
+// WithQuery makes e match only requests with the query key set to value.
+func (e *Expectation) WithQuery(key, value string) *Expectation {
+	e.query[key] = value
+	return e
+}
+
+// WithHeader makes e match only requests with the header key set to value.
+func (e *Expectation) WithHeader(key, value string) *Expectation {
+	e.header.Set(key, value)
+	return e
+}
+
+// WithBody makes e match only requests with body.
+func (e *Expectation) WithBody(body string) *Expectation {
+	e.body = &body
+	return e
+}
+
+// Reply adds a reply with status to e. Each reply is used by one call, in
+// order, so that replies registered one after another form a sequence;
+// the last one is repeated if e is called more, see Times.
+// Body, JSON, Header and Delay configure the last reply.
+func (e *Expectation) Reply(status int) *Expectation {
+	e.steps = append(e.steps, &step{status: status, header: make(http.Header)})
+	return e
+}
+
+// ReplyError adds a reply failing with err, such as a network error, see Reply.
+func (e *Expectation) ReplyError(err error) *Expectation {
+	e.steps = append(e.steps, &step{err: err})
+	return e
+}
+
+// Body sets the body of the last reply.
+func (e *Expectation) Body(body string) *Expectation {
+	s := e.lastStep()
+	s.body = []byte(body)
+	return e
+}
+
+// JSON sets the body of the last reply to v encoded as JSON,
+// and its Content-Type to application/json.
+// It fails the test if v can't be encoded.
+func (e *Expectation) JSON(v interface{}) *Expectation {
+	body, err := json.Marshal(v)
+	if err != nil {
+		e.m.t.Helper()
+		e.m.t.Errorf("marshal JSON reply of %s failed: %v", e, err)
+		return e
+	}
+	s := e.lastStep()
+	s.body = body
+	s.header.Set(httpclient.HeaderContentType, httpclient.ContentTypeJson)
+	return e
+}
+
+// Header sets a header of the last reply.
+func (e *Expectation) Header(key, value string) *Expectation {
+	e.lastStep().header.Set(key, value)
+	return e
+}
+
+// Delay makes the last reply sent after d, or when the request is canceled.
+func (e *Expectation) Delay(d time.Duration) *Expectation {
+	e.lastStep().delay = d
+	return e
+}
+
+// Times sets the number of calls expected, instead of one per reply.
+func (e *Expectation) Times(n int) *Expectation {
+	e.times = n
+	return e
+}
+
+// AnyTimes makes e match any number of calls, including none.
+func (e *Expectation) AnyTimes() *Expectation {
+	e.times = -1
+	return e
+}
+
+// Calls returns the calls received by e so far.
+func (e *Expectation) Calls() []*Call {
+	e.m.mu.Lock()
+	defer e.m.mu.Unlock()
+	return append([]*Call(nil), e.calls...)
+}
+
+func (e *Expectation) lastStep() *step {
+	if len(e.steps) == 0 {
+		e.Reply(http.StatusOK)
+	}
+	s := e.steps[len(e.steps)-1]
+	if s.header == nil {
+		s.header = make(http.Header)
+	}
+	return s
+}
+
+func (e *Expectation) expectedCalls() int {
+	if e.times > 0 {
+		return e.times
+	}
+	if len(e.steps) == 0 {
+		return 1
+	}
+	return len(e.steps)
+}
+
+func (e *Expectation) exhausted() bool {
+	return e.times >= 0 && len(e.calls) >= e.expectedCalls()
+}
+
+// next records call and returns the reply to it.
+func (e *Expectation) next(call *Call) *step {
+	e.calls = append(e.calls, call)
+	if len(e.steps) == 0 {
+		return &step{status: http.StatusOK, header: make(http.Header)}
+	}
+	i := len(e.calls) - 1
+	if i >= len(e.steps) {
+		i = len(e.steps) - 1
+	}
+	return e.steps[i]
+}
+
+// match returns the path params if req matches e, nil otherwise.
+func (e *Expectation) match(req *http.Request, body []byte) map[string]string {
+	if req.Method != e.method {
+		return nil
+	}
+	segments := splitPath(req.URL.Path)
+	if len(segments) != len(e.pattern) {
+		return nil
+	}
+	params := make(map[string]string)
+	for i, p := range e.pattern {
+		if strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}") {
+			params[p[1:len(p)-1]] = segments[i]
+		} else if p != segments[i] {
+			return nil
+		}
+	}
+	query := req.URL.Query()
+	for k, v := range e.query {
+		if query.Get(k) != v {
+			return nil
+		}
+	}
+	for k := range e.header {
+		if req.Header.Get(k) != e.header.Get(k) {
+			return nil
+		}
+	}
+	if e.body != nil && string(body) != *e.body {
+		return nil
+	}
+	return params
+}
+
+func splitPath(path string) []string {
+	return strings.Split(strings.Trim(path, "/"), "/")
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer func() {
+		_ = req.Body.Close()
+	}()
+	return ioutil.ReadAll(req.Body)
+}
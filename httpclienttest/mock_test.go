@@ -0,0 +1,225 @@
+package httpclienttest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zrcoder/httpclient"
+)
+
+// fakeT records the failures of a MockTransport, and runs its cleanups on end.
+type fakeT struct {
+	errors   []string
+	cleanups []func()
+}
+
+func (t *fakeT) Helper() {}
+
+func (t *fakeT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func (t *fakeT) Cleanup(fn func()) {
+	t.cleanups = append(t.cleanups, fn)
+}
+
+func (t *fakeT) end() {
+	for _, fn := range t.cleanups {
+		fn()
+	}
+}
+
+func TestMockTransport(t *testing.T) {
+	mock := NewMockTransport(t)
+	mock.On(httpclient.GET, "/users/{id}").WithQuery("fields", "name").
+		Reply(http.StatusOK).JSON(map[string]string{"name": "Alice"}).Header("X-Request-Id", "1")
+	mock.On(httpclient.POST, "/users").WithHeader("X-Token", "t").WithBody(`{"name":"Bob"}`).
+		Reply(http.StatusCreated)
+
+	client := mock.Client().BaseURL("http://api.example.com")
+	var user struct{ Name string }
+	err := client.Get("/users/{id}").PathParam("id", "42").SetQuery("fields", "name").ToJSON(&user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "Alice" {
+		t.Errorf("expected Alice, got %q", user.Name)
+	}
+
+	resp, err := client.Post("/users").Header("X-Token", "t").Body(map[string]string{"name": "Bob"}).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		t.Errorf("expected %d, got %d", http.StatusCreated, resp.StatusCode)
+	}
+}
+
+func TestMockTransportSequence(t *testing.T) {
+	mock := NewMockTransport(t)
+	e := mock.On(httpclient.GET, "/status").
+		Reply(http.StatusServiceUnavailable).
+		Reply(http.StatusOK).Body("ok")
+
+	client := mock.Client().Retry(httpclient.RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond})
+	var s string
+	if err := client.Get("http://api.example.com/status").ToString(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "ok" {
+		t.Errorf("expected ok, got %q", s)
+	}
+	if len(e.Calls()) != 2 {
+		t.Errorf("expected 2 calls, got %d", len(e.Calls()))
+	}
+}
+
+func TestMockTransportParams(t *testing.T) {
+	mock := NewMockTransport(t)
+	e := mock.On(httpclient.DELETE, "/users/{id}/posts/{post}").
+		Reply(http.StatusNoContent).Header("X-Request-Id", "1").Times(2)
+
+	client := mock.Client()
+	for _, id := range []string{"1", "2"} {
+		resp, err := client.Delete("http://api.example.com/users/" + id + "/posts/7").Go()
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		if resp.Header.Get("X-Request-Id") != "1" {
+			t.Errorf("unexpected header %v", resp.Header)
+		}
+	}
+	calls := e.Calls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+	if calls[1].Params["id"] != "2" || calls[1].Params["post"] != "7" {
+		t.Errorf("unexpected params %v", calls[1].Params)
+	}
+}
+
+func TestMockTransportConcurrentCalls(t *testing.T) {
+	mock := NewMockTransport(t)
+	e := mock.On(httpclient.GET, "/").Reply(http.StatusOK).Times(10)
+
+	client := mock.Client()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			resp, err := client.Get("http://api.example.com/").Go()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = resp.Body.Close()
+		}
+	}()
+	// read the calls while the requests run, for the race detector
+	for running := true; running; {
+		_ = e.Calls()
+		select {
+		case <-done:
+			running = false
+		default:
+		}
+	}
+	if n := len(e.Calls()); n != 10 {
+		t.Errorf("expected 10 calls, got %d", n)
+	}
+}
+
+func TestMockTransportError(t *testing.T) {
+	mock := NewMockTransport(t)
+	errDown := errors.New("connection refused")
+	mock.On(httpclient.GET, "/").ReplyError(errDown)
+
+	_, err := mock.Client().Get("http://api.example.com/").Go()
+	if !errors.Is(err, errDown) {
+		t.Errorf("expected %v, got %v", errDown, err)
+	}
+}
+
+func TestMockTransportDelay(t *testing.T) {
+	mock := NewMockTransport(t)
+	mock.On(httpclient.GET, "/slow").Reply(http.StatusOK).Delay(time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := mock.Client().Get("http://api.example.com/slow").GoContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected the delay to be canceled, took %v", elapsed)
+	}
+}
+
+func TestMockTransportAssertExpectations(t *testing.T) {
+	testCases := []struct {
+		name   string
+		setup  func(m *MockTransport)
+		calls  []string
+		errors []string
+	}{
+		{
+			name:  "met",
+			setup: func(m *MockTransport) { m.On(httpclient.GET, "/a").AnyTimes() },
+		},
+		{
+			name:   "not called",
+			setup:  func(m *MockTransport) { m.On(httpclient.GET, "/a") },
+			errors: []string{"expected GET /a to be called 1 times, got 0"},
+		},
+		{
+			name: "bad JSON",
+			setup: func(m *MockTransport) {
+				m.On(httpclient.GET, "/a").Reply(http.StatusOK).JSON(make(chan int)).AnyTimes()
+			},
+			errors: []string{"marshal JSON reply of GET /a failed: json: unsupported type: chan int"},
+		},
+		{
+			name:  "unexpected",
+			setup: func(m *MockTransport) {},
+			calls: []string{"/b"},
+			errors: []string{
+				"unexpected request GET http://api.example.com/b",
+				"unexpected requests: GET http://api.example.com/b",
+			},
+		},
+		{
+			name:  "exhausted",
+			setup: func(m *MockTransport) { m.On(httpclient.GET, "/a").Reply(http.StatusOK) },
+			calls: []string{"/a", "/a"},
+			errors: []string{
+				"unexpected request GET http://api.example.com/a",
+				"unexpected requests: GET http://api.example.com/a",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ft := &fakeT{}
+			mock := NewMockTransport(ft)
+			tc.setup(mock)
+			for _, path := range tc.calls {
+				resp, err := mock.Client().Get("http://api.example.com" + path).Go()
+				if err == nil {
+					_ = resp.Body.Close()
+				}
+			}
+			ft.end()
+			if strings.Join(ft.errors, "\n") != strings.Join(tc.errors, "\n") {
+				t.Errorf("expected errors %q, got %q", tc.errors, ft.errors)
+			}
+		})
+	}
+}
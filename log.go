@@ -0,0 +1,188 @@
+package httpclient
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Redacted replaces the values of sensitive headers and queries in logs.
+const Redacted = "REDACTED"
+
+var (
+	// DefaultRedactedHeaders are the headers always redacted in logs.
+	DefaultRedactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+	// DefaultRedactedQueries are the query parameters redacted in logs
+	// when LogOptions.RedactQueries is nil.
+	DefaultRedactedQueries = []string{"access_token", "api_key", "apikey", "password", "secret", "signature", "token"}
+)
+
+// Logger logs a message with alternating keys and values.
+// A *slog.Logger satisfies it.
+type Logger interface {
+	Info(msg string, keyvals ...interface{})
+	Error(msg string, keyvals ...interface{})
+}
+
+// LogOptions configures what is logged besides the method, url, status,
+// duration, sizes and attempt of requests, see Client.Logger.
+// The sizes, request_bytes and response_bytes, are the Content-Length
+// declared by the request and response, -1 if unknown, such as for a
+// chunked body or one decompressed by the transport.
+type LogOptions struct {
+	// Headers makes request and response headers logged.
+	Headers bool
+	// BodyLimit is the max number of request and response body bytes logged,
+	// 0 logs no body.
+	BodyLimit int
+	// RedactHeaders are redacted in addition to DefaultRedactedHeaders.
+	RedactHeaders []string
+	// RedactQueries are the redacted query parameters,
+	// nil means DefaultRedactedQueries.
+	RedactQueries []string
+}
+
+// Logger makes every attempt of the requests sent by c logged to logger,
+// after it completes. Failed attempts are logged as errors.
+func (c *Client) Logger(logger Logger) *Client {
+	c.logger = logger
+	return c
+}
+
+// LogOptions configures the logs of Logger.
+func (c *Client) LogOptions(opts LogOptions) *Client {
+	c.logOptions = opts
+	return c
+}
+
+// logRoundTrip sends req as the given attempt, logging it if c has a logger.
+func (c *Client) logRoundTrip(req *http.Request, attempt int) (*http.Response, error) {
+	if c.logger == nil {
+		return c.roundTrip(req)
+	}
+	opts := c.logOptions
+	keyvals := []interface{}{
+		"method", req.Method,
+		"url", redactUrl(req.URL, opts.redactedQueries()),
+		"attempt", attempt,
+		"request_bytes", requestLength(req),
+	}
+	if opts.Headers {
+		keyvals = append(keyvals, "request_headers", opts.redactHeader(req.Header))
+	}
+	if opts.BodyLimit > 0 && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			keyvals = append(keyvals, "request_body", readLimit(body, opts.BodyLimit))
+			_ = body.Close()
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.roundTrip(req)
+	keyvals = append(keyvals, "duration", time.Since(start))
+	if err != nil {
+		c.logger.Error("http request failed", append(keyvals, "error", opts.redactError(unwrapFinal(err)))...)
+		return nil, err
+	}
+	keyvals = append(keyvals, "status", resp.StatusCode, "response_bytes", resp.ContentLength)
+	if opts.Headers {
+		keyvals = append(keyvals, "response_headers", opts.redactHeader(resp.Header))
+	}
+	if opts.BodyLimit > 0 {
+		prefix := readLimit(resp.Body, opts.BodyLimit)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(strings.NewReader(prefix), resp.Body), resp.Body}
+		keyvals = append(keyvals, "response_body", prefix)
+	}
+	c.logger.Info("http request", keyvals...)
+	return resp, nil
+}
+
+func (o LogOptions) redactedQueries() []string {
+	if o.RedactQueries == nil {
+		return DefaultRedactedQueries
+	}
+	return o.RedactQueries
+}
+
+// redactHeader returns a copy of header with the sensitive values redacted.
+func (o LogOptions) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for _, names := range [][]string{DefaultRedactedHeaders, o.RedactHeaders} {
+		for _, name := range names {
+			if values := redacted.Values(name); len(values) > 0 {
+				redacted.Del(name)
+				for range values {
+					redacted.Add(name, Redacted)
+				}
+			}
+		}
+	}
+	return redacted
+}
+
+// redactUrl returns u with its password and the values of queries redacted.
+func redactUrl(u *url.URL, queries []string) string {
+	redacted := *u
+	if _, ok := redacted.User.Password(); ok {
+		redacted.User = url.UserPassword(redacted.User.Username(), Redacted)
+	}
+	if redacted.RawQuery != "" {
+		values := redacted.Query()
+		changed := false
+		for _, name := range queries {
+			for key, vs := range values {
+				if !strings.EqualFold(key, name) {
+					continue
+				}
+				for i := range vs {
+					vs[i] = Redacted
+				}
+				changed = true
+			}
+		}
+		if changed {
+			redacted.RawQuery = values.Encode()
+		}
+	}
+	return redacted.String()
+}
+
+// redactError returns err with the url it reports redacted,
+// as a *url.Error of the http.Client holds the full url of the request.
+func (o LogOptions) redactError(err error) error {
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		return err
+	}
+	u, parseErr := url.Parse(urlErr.URL)
+	if parseErr != nil {
+		return err
+	}
+	redacted := *urlErr
+	redacted.URL = redactUrl(u, o.redactedQueries())
+	if err == urlErr {
+		return &redacted
+	}
+	return errors.New(strings.Replace(err.Error(), urlErr.URL, redacted.URL, -1))
+}
+
+// requestLength returns the Content-Length of req, -1 if unknown.
+func requestLength(req *http.Request) int64 {
+	if req.ContentLength == 0 && req.Body != nil && req.Body != http.NoBody {
+		return -1
+	}
+	return req.ContentLength
+}
+
+func readLimit(r io.Reader, limit int) string {
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, io.LimitReader(r, int64(limit)))
+	return buf.String()
+}
@@ -0,0 +1,107 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+)
+
+// RoundTripFunc sends a request and returns its response.
+// It implements http.RoundTripper, so it can also be used as a Transport.
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Middleware wraps the round trip of the requests sent by a Client,
+// such as to add auth headers, log or collect metrics.
+// It can abort a request by returning an error without calling next.
+type Middleware func(next RoundTripFunc) RoundTripFunc
+
+// Use adds middlewares wrapping the round trip of every attempt of the
+// requests sent by c. The first one added is the outermost one, so it sees
+// the request first and the response last.
+func (c *Client) Use(mw ...Middleware) *Client {
+	c.middlewares = append(c.middlewares, mw...)
+	return c
+}
+
+// UseTransport adds middlewares wrapping the transport of c, below the
+// redirects followed by the http.Client, so that they see each exchange
+// of a request, including every redirect, rather than the request as a whole.
+// The first one added is the outermost one.
+func (c *Client) UseTransport(mw ...Middleware) *Client {
+	c.transportMiddlewares = append(c.transportMiddlewares, mw...)
+	return c
+}
+
+// OnBeforeRequest adds a hook run before every attempt of the requests sent by c,
+// before the middlewares. Hooks run in the order they're added,
+// and the first one returning an error aborts the request.
+func (c *Client) OnBeforeRequest(fn func(req *http.Request) error) *Client {
+	c.beforeRequest = append(c.beforeRequest, fn)
+	return c
+}
+
+// OnAfterResponse adds a hook run after every response received by c,
+// after the middlewares. Hooks run in the order they're added,
+// and the first one returning an error makes the request fail with it,
+// closing the response body.
+func (c *Client) OnAfterResponse(fn func(resp *http.Response) error) *Client {
+	c.afterResponse = append(c.afterResponse, fn)
+	return c
+}
+
+// roundTrip sends req through the hooks and middlewares of c.
+// Errors of the hooks and middlewares, rather than of the http.Client,
+// are final, so that the request isn't retried.
+func (c *Client) roundTrip(req *http.Request) (*http.Response, error) {
+	for _, fn := range c.beforeRequest {
+		if err := fn(req); err != nil {
+			return nil, &finalError{err}
+		}
+	}
+	client := c.httpClient()
+	var doErr error
+	next := RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := client.Do(req)
+		doErr = err
+		return resp, err
+	})
+	for i := len(c.middlewares) - 1; i >= 0; i-- {
+		next = c.middlewares[i](next)
+	}
+	resp, err := next(req)
+	if err != nil {
+		if doErr == nil || !errors.Is(err, doErr) {
+			return nil, &finalError{err}
+		}
+		return nil, err
+	}
+	for _, fn := range c.afterResponse {
+		if err = fn(resp); err != nil {
+			_ = resp.Body.Close()
+			return nil, &finalError{err}
+		}
+	}
+	return resp, nil
+}
+
+// httpClient returns the http.Client sending the requests of c, with its
+// transport wrapped by the transport middlewares.
+func (c *Client) httpClient() *http.Client {
+	if len(c.transportMiddlewares) == 0 {
+		return c.client
+	}
+	transport := c.client.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	next := RoundTripFunc(transport.RoundTrip)
+	for i := len(c.transportMiddlewares) - 1; i >= 0; i-- {
+		next = c.transportMiddlewares[i](next)
+	}
+	client := *c.client
+	client.Transport = next
+	return &client
+}
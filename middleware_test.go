@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Auth", r.Header.Get("Authorization"))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var calls []string
+	trace := func(name string) Middleware {
+		return func(next RoundTripFunc) RoundTripFunc {
+			return func(req *http.Request) (*http.Response, error) {
+				calls = append(calls, name+" before")
+				resp, err := next(req)
+				calls = append(calls, name+" after")
+				return resp, err
+			}
+		}
+	}
+	client := New().
+		Use(trace("outer"), trace("inner")).
+		OnBeforeRequest(func(req *http.Request) error {
+			calls = append(calls, "hook before 1")
+			req.Header.Set("Authorization", "Bearer token")
+			return nil
+		}).
+		OnBeforeRequest(func(req *http.Request) error {
+			calls = append(calls, "hook before 2")
+			return nil
+		}).
+		OnAfterResponse(func(resp *http.Response) error {
+			calls = append(calls, "hook after 1")
+			return nil
+		}).
+		OnAfterResponse(func(resp *http.Response) error {
+			calls = append(calls, "hook after 2")
+			return nil
+		})
+
+	resp, err := client.Get(server.URL).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.Header.Get("X-Auth") != "Bearer token" {
+		t.Error("expected the header injected by the hook to be sent")
+	}
+	expected := "hook before 1, hook before 2, outer before, inner before, inner after, outer after, hook after 1, hook after 2"
+	if got := strings.Join(calls, ", "); got != expected {
+		t.Errorf("expected calls %s, got %s", expected, got)
+	}
+
+	errAbort := errors.New("abort")
+	abort := func(next RoundTripFunc) RoundTripFunc {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, errAbort
+		}
+	}
+	aborted := map[string]*Client{
+		"middleware": New().Use(abort),
+		"before": New().OnBeforeRequest(func(req *http.Request) error {
+			return errAbort
+		}),
+		"after": New().OnAfterResponse(func(resp *http.Response) error {
+			return errAbort
+		}),
+	}
+	for name, client := range aborted {
+		resp, err := client.Get(server.URL).Go()
+		if resp != nil || !errors.Is(err, errAbort) {
+			t.Errorf("%s: expected the request to be aborted, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+// PinError is returned when no certificate presented by the server
+// matches the pins set by PinSHA256.
+type PinError struct {
+	// Pins are the pins of the certificates presented by the server.
+	Pins []string
+}
+
+func (e *PinError) Error() string {
+	return fmt.Sprintf("no certificate in the server chain matches the pinned public keys, got %s",
+		strings.Join(e.Pins, ", "))
+}
+
+// SPKISHA256 returns the pin of cert: the base64 encoded SHA-256 hash of
+// its Subject Public Key Info, as used by PinSHA256.
+func SPKISHA256(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// PinSHA256 makes the connection fail with a *PinError unless a certificate
+// in the server chain has one of pins, see SPKISHA256. A pin may be prefixed
+// with "sha256/". Calling it again adds backup pins.
+// The pins stay checked when the TLS config or the transport is replaced
+// afterwards; a transport other than an *http.Transport keeps an error.
+// Pinning runs after, not instead of, the usual chain verification configured
+// by AddCACert, CertPool and so on; only with InsecureSkipVerify are the
+// certificates sent by the server checked without being verified.
+func (c *Client) PinSHA256(pins ...string) *Client {
+	config := c.judge2genTlsConfig("PinSHA256")
+	if config == nil {
+		return c
+	}
+	for _, pin := range pins {
+		sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, "sha256/"))
+		if err != nil || len(sum) != sha256.Size {
+			c.keepOriginErr(fmt.Errorf("invalid SHA-256 pin %q", pin))
+			return c
+		}
+		if c.pins == nil {
+			c.pins = make(map[string]bool)
+		}
+		c.pins[base64.StdEncoding.EncodeToString(sum)] = true
+	}
+	c.installPins()
+	return c
+}
+
+// installPins makes the TLS config of the transport check the pins, if any.
+// It's called again whenever the config or the transport is replaced, so
+// that pinning can't be dropped silently. The config is cloned before its
+// VerifyPeerCertificate is wrapped, leaving the one passed to TlsConfig as it is.
+func (c *Client) installPins() {
+	if len(c.pins) == 0 {
+		return
+	}
+	config := c.judge2genTlsConfig("PinSHA256")
+	if config == nil || config == c.pinnedConfig {
+		return
+	}
+	config = config.Clone()
+	verify := config.VerifyPeerCertificate
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if verify != nil {
+			if err := verify(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+		return c.verifyPins(rawCerts, verifiedChains)
+	}
+	c.transport.TLSClientConfig = config
+	c.pinnedConfig = config
+}
+
+func (c *Client) verifyPins(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	chains := verifiedChains
+	if len(chains) == 0 {
+		certs := make([]*x509.Certificate, 0, len(rawCerts))
+		for _, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return err
+			}
+			certs = append(certs, cert)
+		}
+		chains = [][]*x509.Certificate{certs}
+	}
+	pinErr := &PinError{}
+	seen := make(map[string]bool)
+	for _, chain := range chains {
+		for _, cert := range chain {
+			pin := SPKISHA256(cert)
+			if c.pins[pin] {
+				return nil
+			}
+			if !seen[pin] {
+				seen[pin] = true
+				pinErr.Pins = append(pinErr.Pins, pin)
+			}
+		}
+	}
+	return pinErr
+}
@@ -0,0 +1,65 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestPinSHA256(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestTLSServer(t, ca)
+	defer server.Close()
+
+	leafPin := SPKISHA256(server.Certificate())
+	caPin := "sha256/" + SPKISHA256(ca.cert)
+	otherPin := SPKISHA256(newTestCA(t).cert)
+
+	accepted := map[string]*Client{
+		"leaf":        New().AddCACert(ca.cert).PinSHA256(leafPin),
+		"CA":          New().AddCACert(ca.cert).PinSHA256(caPin),
+		"backup":      New().AddCACert(ca.cert).PinSHA256(otherPin).PinSHA256(leafPin),
+		"insecure":    New().InsecureSkipVerify(true).PinSHA256(leafPin),
+		"new config":  New().PinSHA256(caPin).TlsConfig(ca.tlsConfig()),
+		"before pool": New().PinSHA256(leafPin).AddCAContent(ca.certPEM),
+	}
+	for name, client := range accepted {
+		var s string
+		if err := client.Get(server.URL).ToString(&s); err != nil {
+			t.Errorf("%s: expected the pinned server to be accepted, got %v", name, err)
+		}
+	}
+
+	config := ca.tlsConfig()
+	rejected := map[string]*Client{
+		"verified":      New().AddCACert(ca.cert).PinSHA256(otherPin),
+		"insecure":      New().InsecureSkipVerify(true).PinSHA256(otherPin),
+		"new config":    New().PinSHA256(otherPin).TlsConfig(config),
+		"new transport": New().Transport(&http.Transport{TLSClientConfig: ca.tlsConfig()}).PinSHA256(otherPin),
+		"transport":     New().PinSHA256(otherPin).Transport(&http.Transport{TLSClientConfig: ca.tlsConfig()}),
+		"http client":   New().PinSHA256(otherPin).HTTPClient(&http.Client{Transport: &http.Transport{TLSClientConfig: ca.tlsConfig()}}),
+	}
+	for name, client := range rejected {
+		_, err := client.Get(server.URL).Go()
+		var pinErr *PinError
+		if !errors.As(err, &pinErr) {
+			t.Errorf("%s: expected a *PinError, got %v", name, err)
+			continue
+		}
+		if len(pinErr.Pins) == 0 || pinErr.Pins[0] != leafPin {
+			t.Errorf("%s: expected the server pins in the error, got %v", name, pinErr.Pins)
+		}
+	}
+
+	if config.VerifyPeerCertificate != nil {
+		t.Error("expected the config passed to TlsConfig left as it is")
+	}
+	foreign := New().PinSHA256(otherPin).Transport(RoundTripFunc(http.DefaultTransport.RoundTrip))
+	if _, err := foreign.Get(server.URL).Go(); err == nil {
+		t.Error("expected an error for pins that can't be checked by the transport")
+	}
+
+	if _, err := New().PinSHA256("not a pin").Get(server.URL).Go(); err == nil {
+		t.Error("expected an error for an invalid pin")
+	}
+}
@@ -0,0 +1,103 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/youmark/pkcs8"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// AddPKCS12File adds the client certificate and key in the PKCS#12 (.p12, .pfx)
+// file, see AddPKCS12Content.
+func (c *Client) AddPKCS12File(path, password string) *Client {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	c.curlArgs = append(c.curlArgs, "--cert-type", "P12", "--cert", path)
+	return c.AddPKCS12Content(content, password)
+}
+
+// AddPKCS12Content adds the client certificate and key in the PKCS#12 content,
+// presenting them with the CA certificates of the bundle, which are also added
+// to the roots used to verify servers.
+func (c *Client) AddPKCS12Content(content []byte, password string) *Client {
+	key, leaf, caCerts, err := pkcs12.DecodeChain(content, password)
+	if err != nil {
+		c.keepOriginErr(fmt.Errorf("decode PKCS#12 failed: %w", err))
+		return c
+	}
+	cert := tls.Certificate{
+		Certificate: [][]byte{leaf.Raw},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}
+	for _, ca := range caCerts {
+		cert.Certificate = append(cert.Certificate, ca.Raw)
+		c.AddCACert(ca)
+	}
+	return c.AddCert(cert)
+}
+
+// AddCertContentWithPassword is like AddCertContent, but the PEM key is an
+// ENCRYPTED PRIVATE KEY (PKCS#8) encrypted with password.
+// Keys encrypted with the legacy Proc-Type and DEK-Info headers keep an
+// error, see AddLegacyCertContentWithPassword.
+func (c *Client) AddCertContentWithPassword(certContent, keyContent []byte, password string) *Client {
+	keyContent, err := decryptPEMKey(keyContent, []byte(password), false)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	return c.AddCertContent(certContent, keyContent)
+}
+
+// AddLegacyCertContentWithPassword is like AddCertContentWithPassword, but
+// also accepts keys encrypted with the legacy Proc-Type and DEK-Info headers.
+// That encryption is insecure by design, as it's open to padding oracle
+// attacks; use it only for keys handed out by PKIs that know no better.
+func (c *Client) AddLegacyCertContentWithPassword(certContent, keyContent []byte, password string) *Client {
+	keyContent, err := decryptPEMKey(keyContent, []byte(password), true)
+	if err != nil {
+		c.keepOriginErr(err)
+		return c
+	}
+	return c.AddCertContent(certContent, keyContent)
+}
+
+// decryptPEMKey returns the PEM key in content, decrypted with password.
+// legacy allows the insecure Proc-Type encryption.
+func decryptPEMKey(content, password []byte, legacy bool) ([]byte, error) {
+	block, _ := pem.Decode(content)
+	if block == nil {
+		return nil, errors.New("no PEM key found")
+	}
+	switch {
+	case block.Type == "ENCRYPTED PRIVATE KEY":
+		key, err := pkcs8.ParsePKCS8PrivateKey(block.Bytes, password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt private key failed, the password may be wrong: %w", err)
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+	case x509.IsEncryptedPEMBlock(block):
+		if !legacy {
+			return nil, errors.New("the private key uses the insecure legacy PEM encryption, use AddLegacyCertContentWithPassword to accept it")
+		}
+		der, err := x509.DecryptPEMBlock(block, password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt private key failed: %w", err)
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: block.Type, Bytes: der}), nil
+	}
+	return nil, fmt.Errorf("PEM block %q is not an encrypted private key", block.Type)
+}
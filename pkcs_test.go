@@ -0,0 +1,76 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+const testPassword = "secret"
+
+func readTestdata(t *testing.T, name string) []byte {
+	t.Helper()
+	content, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestClientIdentity(t *testing.T) {
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(readTestdata(t, "ca.crt")) {
+		t.Fatal("no CA in testdata/ca.crt")
+	}
+	ca := newTestCA(t)
+	serverCert, _, _ := ca.issue(t)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	certPEM := readTestdata(t, "client.crt")
+	clients := map[string]*Client{
+		"PKCS#12":        New().AddCACert(ca.cert).AddPKCS12File(filepath.Join("testdata", "client.p12"), testPassword),
+		"legacy PKCS#12": New().AddCACert(ca.cert).AddPKCS12Content(readTestdata(t, "client-legacy.p12"), testPassword),
+		"PKCS#8 AES":     New().AddCACert(ca.cert).AddCertContentWithPassword(certPEM, readTestdata(t, "client-pkcs8.key"), testPassword),
+		"PKCS#8 3DES":    New().AddCACert(ca.cert).AddCertContentWithPassword(certPEM, readTestdata(t, "client-pkcs8-des3.key"), testPassword),
+		"legacy PEM":     New().AddCACert(ca.cert).AddLegacyCertContentWithPassword(certPEM, readTestdata(t, "client-legacy.key"), testPassword),
+		"legacy PKCS#8":  New().AddCACert(ca.cert).AddLegacyCertContentWithPassword(certPEM, readTestdata(t, "client-pkcs8.key"), testPassword),
+	}
+	for name, client := range clients {
+		var s string
+		if err := client.Get(server.URL).ToString(&s); err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if s != "httpclient test client" {
+			t.Errorf("%s: expected the client certificate to be presented, got %q", name, s)
+		}
+	}
+
+	invalid := map[string]*Client{
+		"PKCS#12":     New().AddPKCS12Content(readTestdata(t, "client.p12"), "wrong"),
+		"PKCS#12 CA":  New().AddPKCS12Content(readTestdata(t, "ca.crt"), testPassword),
+		"PKCS#8":      New().AddCertContentWithPassword(certPEM, readTestdata(t, "client-pkcs8.key"), "wrong"),
+		"legacy PEM":  New().AddLegacyCertContentWithPassword(certPEM, readTestdata(t, "client-legacy.key"), "wrong"),
+		"no opt-in":   New().AddCertContentWithPassword(certPEM, readTestdata(t, "client-legacy.key"), testPassword),
+		"not a key":   New().AddCertContentWithPassword(certPEM, certPEM, testPassword),
+		"missing p12": New().AddPKCS12File(filepath.Join("testdata", "missing.p12"), testPassword),
+	}
+	for name, client := range invalid {
+		if _, err := client.Get(server.URL).Go(); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
@@ -0,0 +1,315 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// RecorderMode tells a Recorder whether to record or replay interactions.
+type RecorderMode int
+
+const (
+	// ModeRecord sends requests to the network and saves the interactions,
+	// overwriting the cassette.
+	ModeRecord RecorderMode = iota
+	// ModeReplay serves responses from the cassette, without network.
+	ModeReplay
+	// ModeReplayOrRecord replays if the cassette exists, and records it otherwise.
+	ModeReplayOrRecord
+)
+
+// Interaction is a request and its response, as saved in a cassette.
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest is a request saved in a cassette.
+type RecordedRequest struct {
+	Method string       `json:"method"`
+	URL    string       `json:"url"`
+	Header http.Header  `json:"header,omitempty"`
+	Body   RecordedBody `json:"body,omitempty"`
+}
+
+// RecordedResponse is a response saved in a cassette.
+type RecordedResponse struct {
+	StatusCode int          `json:"status_code"`
+	Header     http.Header  `json:"header,omitempty"`
+	Body       RecordedBody `json:"body,omitempty"`
+}
+
+// RecordedBody is a body saved as a string if it's valid UTF-8,
+// and as base64 otherwise.
+type RecordedBody []byte
+
+func (b RecordedBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *RecordedBody) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = RecordedBody(s)
+		return nil
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded["base64"])
+	*b = decoded
+	return err
+}
+
+// Matcher reports whether a request matches a recorded one. The request is
+// compared as it would be recorded, with its secrets redacted.
+type Matcher func(req, recorded *RecordedRequest) bool
+
+// MatchMethod matches requests with the same method.
+func MatchMethod(req, recorded *RecordedRequest) bool {
+	return req.Method == recorded.Method
+}
+
+// MatchURL matches requests with the same url, including queries.
+func MatchURL(req, recorded *RecordedRequest) bool {
+	return req.URL == recorded.URL
+}
+
+// MatchBody matches requests with the same body.
+func MatchBody(req, recorded *RecordedRequest) bool {
+	return bytes.Equal(req.Body, recorded.Body)
+}
+
+// MatchHeaders returns a Matcher matching requests with the same values of headers.
+func MatchHeaders(headers ...string) Matcher {
+	return func(req, recorded *RecordedRequest) bool {
+		for _, h := range headers {
+			if strings.Join(req.Header.Values(h), ",") != strings.Join(recorded.Header.Values(h), ",") {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Recorder is an http.RoundTripper that records interactions to a JSON
+// cassette file, or replays them from it, making tests deterministic.
+// Use it as the Transport of a Client, or with Use(recorder.Middleware) to
+// record through the client's own transport.
+type Recorder struct {
+	// Next sends the requests to record, nil means http.DefaultTransport.
+	Next http.RoundTripper
+	// Matchers select the recorded interaction replayed for a request,
+	// nil means MatchMethod and MatchURL.
+	Matchers []Matcher
+	// RedactHeaders and RedactQueries are redacted before saving, in addition
+	// to DefaultRedactedHeaders and DefaultRedactedQueries.
+	RedactHeaders []string
+	RedactQueries []string
+	// Redact, if set, is called on each interaction before it's saved,
+	// such as to remove secrets from bodies. When replaying, it's called on
+	// the request alone, before matching it.
+	Redact func(*Interaction)
+
+	path         string
+	mode         RecorderMode
+	mu           sync.Mutex
+	interactions []*Interaction
+	replayed     []bool
+}
+
+type cassette struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// NewRecorder returns a Recorder for the cassette at path, loading it
+// unless it's going to be recorded.
+func NewRecorder(path string, mode RecorderMode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode}
+	if mode == ModeReplayOrRecord {
+		r.mode = ModeRecord
+		if _, err := os.Stat(path); err == nil {
+			r.mode = ModeReplay
+		}
+	}
+	if r.mode != ModeReplay {
+		return r, nil
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c cassette
+	if err = json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s failed: %w", path, err)
+	}
+	r.interactions = c.Interactions
+	r.replayed = make([]bool, len(c.Interactions))
+	return r, nil
+}
+
+// Mode returns the mode the recorder is working in.
+func (r *Recorder) Mode() RecorderMode {
+	return r.mode
+}
+
+// RoundTrip records or replays req.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	next := r.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return r.Middleware(next.RoundTrip)(req)
+}
+
+// Middleware records or replays the requests sent through it, see Client.Use.
+func (r *Recorder) Middleware(next RoundTripFunc) RoundTripFunc {
+	return func(req *http.Request) (*http.Response, error) {
+		req, recorded, err := r.recordRequest(req)
+		if err != nil {
+			return nil, err
+		}
+		if r.mode == ModeReplay {
+			return r.replay(req, recorded)
+		}
+		resp, err := next(req)
+		if err != nil {
+			return nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+		interaction := &Interaction{
+			Request: *recorded,
+			Response: RecordedResponse{
+				StatusCode: resp.StatusCode,
+				Header:     r.redactHeader(resp.Header),
+				Body:       body,
+			},
+		}
+		if r.Redact != nil {
+			r.Redact(interaction)
+		}
+		if err = r.save(interaction); err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+// recordRequest returns req as it's recorded. As req can't be modified,
+// a clone with the body read again is returned to be sent instead.
+func (r *Recorder) recordRequest(req *http.Request) (*http.Request, *RecordedRequest, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		_ = req.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return req, &RecordedRequest{
+		Method: req.Method,
+		URL:    redactUrl(req.URL, append(append([]string(nil), DefaultRedactedQueries...), r.RedactQueries...)),
+		Header: r.redactHeader(req.Header),
+		Body:   body,
+	}, nil
+}
+
+func (r *Recorder) redactHeader(header http.Header) http.Header {
+	return LogOptions{RedactHeaders: r.RedactHeaders}.redactHeader(header)
+}
+
+func (r *Recorder) replay(req *http.Request, recorded *RecordedRequest) (*http.Response, error) {
+	matchers := r.Matchers
+	if matchers == nil {
+		matchers = []Matcher{MatchMethod, MatchURL}
+	}
+	if r.Redact != nil {
+		// redact the request as it would be when recorded, on a copy
+		// leaving the body of req as it is
+		live := &Interaction{Request: *recorded}
+		live.Request.Header = recorded.Header.Clone()
+		live.Request.Body = append(RecordedBody(nil), recorded.Body...)
+		r.Redact(live)
+		recorded = &live.Request
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, interaction := range r.interactions {
+		if r.replayed[i] || !matches(matchers, recorded, &interaction.Request) {
+			continue
+		}
+		r.replayed[i] = true
+		resp := interaction.Response
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+			StatusCode:    resp.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        resp.Header.Clone(),
+			Body:          ioutil.NopCloser(bytes.NewReader(resp.Body)),
+			ContentLength: int64(len(resp.Body)),
+			Request:       req,
+		}, nil
+	}
+	return nil, fmt.Errorf("no recorded interaction in %s matches %s %s", r.path, recorded.Method, recorded.URL)
+}
+
+func matches(matchers []Matcher, req, recorded *RecordedRequest) bool {
+	for _, match := range matchers {
+		if !match(req, recorded) {
+			return false
+		}
+	}
+	return true
+}
+
+// save adds interaction to the cassette and writes it.
+func (r *Recorder) save(interaction *Interaction) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction)
+	content, err := json.MarshalIndent(cassette{Interactions: r.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(r.path, content, 0644)
+}
+
+// Unreplayed returns an error listing the recorded interactions that weren't
+// replayed, so tests can check all of them were used.
+func (r *Recorder) Unreplayed() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var missing []string
+	for i, interaction := range r.interactions {
+		if r.mode == ModeReplay && !r.replayed[i] {
+			missing = append(missing, interaction.Request.Method+" "+interaction.Request.URL)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return errors.New("interactions not replayed: " + strings.Join(missing, ", "))
+}
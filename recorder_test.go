@@ -0,0 +1,196 @@
+package httpclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ssion"})
+		switch r.URL.Path {
+		case "/binary":
+			_, _ = w.Write([]byte{0xff, 0x00, 0xfe})
+		default:
+			_, _ = w.Write(append([]byte(r.Method+" "), body...))
+		}
+	}))
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "cassette.json")
+
+	calls := func(client *Client) []string {
+		var results []string
+		for _, r := range []*Request{
+			client.Post(server.URL+"/echo").AppendQuery("token", "t0ken").Header("Authorization", "Bearer t0ken").Body("first"),
+			client.Post(server.URL+"/echo").AppendQuery("token", "t0ken").Body("second"),
+			client.Get(server.URL + "/binary"),
+		} {
+			var s string
+			if err := r.ExpectOK().ToString(&s); err != nil {
+				t.Fatal(err)
+			}
+			results = append(results, s)
+		}
+		return results
+	}
+
+	recorder, err := NewRecorder(path, ModeReplayOrRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Mode() != ModeRecord {
+		t.Fatal("expected a missing cassette to be recorded")
+	}
+	recorder.Redact = func(i *Interaction) {
+		i.Response.Body = bytes.Replace(i.Response.Body, []byte("second"), []byte("2nd"), -1)
+	}
+	recorded := calls(New().Use(recorder.Middleware))
+	server.Close()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"t0ken", "s3ssion"} {
+		if bytes.Contains(content, []byte(secret)) {
+			t.Errorf("expected %s to be redacted in the cassette:\n%s", secret, content)
+		}
+	}
+
+	recorder, err = NewRecorder(path, ModeReplayOrRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if recorder.Mode() != ModeReplay {
+		t.Fatal("expected an existing cassette to be replayed")
+	}
+	recorder.Matchers = []Matcher{MatchMethod, MatchURL, MatchBody, MatchHeaders("Authorization")}
+	client := New().Transport(recorder)
+	replayed := calls(client)
+	expected := []string{recorded[0], "POST 2nd", recorded[2]}
+	if strings.Join(replayed, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected replayed responses %q, got %q", expected, replayed)
+	}
+	if replayed[2] != "\xff\x00\xfe" {
+		t.Errorf("expected the binary body to be replayed, got %q", replayed[2])
+	}
+	if err = recorder.Unreplayed(); err != nil {
+		t.Error(err)
+	}
+	if _, err = client.Post(server.URL + "/echo").Body("first").Go(); err == nil {
+		t.Error("expected an error once the matching interaction was replayed")
+	}
+
+	recorder, err = NewRecorder(path, ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = New().Transport(recorder).Get(server.URL + "/unknown").Go(); err == nil {
+		t.Error("expected an error for an unrecorded request")
+	}
+	if err = recorder.Unreplayed(); err == nil {
+		t.Error("expected the unreplayed interactions to be reported")
+	}
+	if _, err = NewRecorder(filepath.Join(dir, "missing.json"), ModeReplay); err == nil {
+		t.Error("expected an error for a missing cassette")
+	}
+}
+
+func TestRecorderRedactBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("logged in"))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	path := filepath.Join(dir, "cassette.json")
+	redact := func(i *Interaction) {
+		i.Request.Body = bytes.Replace(i.Request.Body, []byte("p4ssword"), []byte(Redacted), -1)
+	}
+	login := func(client *Client) (string, error) {
+		var s string
+		err := client.Post(server.URL + "/login").Body(`{"password":"p4ssword"}`).ExpectOK().ToString(&s)
+		return s, err
+	}
+
+	for _, mode := range []RecorderMode{ModeRecord, ModeReplay} {
+		recorder, err := NewRecorder(path, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recorder.Redact = redact
+		recorder.Matchers = []Matcher{MatchMethod, MatchURL, MatchBody}
+		s, err := login(New().Transport(recorder))
+		if err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+		if s != "logged in" {
+			t.Errorf("mode %d: unexpected response %q", mode, s)
+		}
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(content, []byte("p4ssword")) {
+		t.Errorf("expected the password redacted in the cassette:\n%s", content)
+	}
+}
+
+func TestRecorderRoundTripper(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "httpclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+	recorder, err := NewRecorder(filepath.Join(dir, "cassette.json"), ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := ioutil.NopCloser(strings.NewReader("ping"))
+	req, _ := http.NewRequest(POST, server.URL, body)
+	resp, err := recorder.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if req.Body != body {
+		t.Error("expected the request left as it is")
+	}
+
+	// a cassette that can't be saved fails the round trip with no response
+	recorder, err = NewRecorder(filepath.Join(dir, "missing", "cassette.json"), ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ = http.NewRequest(GET, server.URL, nil)
+	if resp, err = recorder.RoundTrip(req); err == nil || resp != nil {
+		t.Errorf("expected only an error, got %v and %v", resp, err)
+	}
+}
@@ -0,0 +1,439 @@
+package httpclient
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Request holds the per-call state of a request, built by Client.Get,
+// Client.Post and so on.
+// A Request is not safe for concurrent use; build a new one for each call.
+type Request struct {
+	client      *Client
+	url         string
+	pathParams  map[string]string
+	queries     url.Values
+	method      string
+	header      http.Header
+	contentType string
+	body        []byte
+	// bodyValue is encoded by the codec of the content type when sent
+	bodyValue interface{}
+	// bodyReader is a body streamed once, bodyFunc one that can be reopened
+	bodyReader    io.Reader
+	bodyFunc      func() (io.ReadCloser, error)
+	contentLength int64
+	form          url.Values
+	parts         []*part
+	// boundary is the one of the multipart body, once it's made
+	boundary string
+	// acceptStatus reports whether a response status is accepted, nil accepts any
+	acceptStatus func(code int) bool
+	err          error
+}
+
+func newRequest(client *Client, method, rawurl string) *Request {
+	return &Request{
+		client:  client,
+		url:     rawurl,
+		method:  method,
+		queries: make(url.Values),
+		header:  make(http.Header),
+	}
+}
+
+func (r *Request) DebugString() string {
+	return fmt.Sprintf("[url]: %s\n"+
+		"[method]: %s\n"+
+		"[header]: %v\n"+
+		"[content type]:%s\n"+
+		"[body]:%s\n",
+		r.getFullUrl(), r.method, r.header, r.contentType, r.debugBody())
+}
+
+func (r *Request) getFullUrl() string {
+	u, err := r.buildUrl()
+	if err != nil {
+		r.keepOriginErr(err)
+		return err.Error()
+	}
+	return u
+}
+
+func (r *Request) buildUrl() (string, error) {
+	rawurl, err := r.expandPath()
+	if err != nil {
+		return "", err
+	}
+	if base := r.client.baseURL; base != "" && !isAbsUrl(rawurl) {
+		rawurl = strings.TrimSuffix(base, "/") + "/" + strings.TrimPrefix(rawurl, "/")
+	}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	if len(r.queries) > 0 {
+		// keep the queries already in the url as they are
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += r.queries.Encode()
+	}
+	return u.String(), nil
+}
+
+// pathTemplate matches a {name} template of a path param.
+var pathTemplate = regexp.MustCompile(`\{[A-Za-z0-9_.-]+\}`)
+
+// expandPath replaces the {name} templates in the path of the url with the
+// escaped path params. Braces in the query or fragment are kept as they are.
+func (r *Request) expandPath() (string, error) {
+	path, rest := r.url, ""
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path, rest = path[:i], path[i:]
+	}
+	var missing string
+	path = pathTemplate.ReplaceAllStringFunc(path, func(template string) string {
+		value, ok := r.pathParams[template[1:len(template)-1]]
+		if !ok {
+			if missing == "" {
+				missing = template
+			}
+			return template
+		}
+		return url.PathEscape(value)
+	})
+	if missing != "" {
+		return "", fmt.Errorf("missing path param %s in %q", missing, r.url)
+	}
+	return path + rest, nil
+}
+
+func isAbsUrl(rawurl string) bool {
+	u, err := url.Parse(rawurl)
+	return err == nil && u.IsAbs()
+}
+
+// PathParam sets the value of the {key} template in the url path,
+// so that Get("/users/{id}").PathParam("id", "42") requests /users/42.
+// The value is escaped as a path segment.
+func (r *Request) PathParam(key, value string) *Request {
+	if r.pathParams == nil {
+		r.pathParams = make(map[string]string)
+	}
+	r.pathParams[key] = value
+	return r
+}
+
+// PathParams sets the values of the templates in the url, see PathParam.
+func (r *Request) PathParams(params map[string]string) *Request {
+	for k, v := range params {
+		r.PathParam(k, v)
+	}
+	return r
+}
+
+// AppendQuery adds the value to key, appending to any existing values.
+func (r *Request) AppendQuery(key, value string) *Request {
+	return r.AddQuery(key, value)
+}
+
+// AppendQueries adds the key value pairs in queries, appending to any existing values.
+func (r *Request) AppendQueries(queries map[string]string) *Request {
+	for k, v := range queries {
+		r.AddQuery(k, v)
+	}
+	return r
+}
+
+// AddQuery adds the value to key, appending to any existing values,
+// so that AddQuery("tag", "a").AddQuery("tag", "b") results in tag=a&tag=b.
+func (r *Request) AddQuery(key, value string) *Request {
+	r.queries.Add(key, value)
+	return r
+}
+
+// SetQuery sets the key to value, replacing any existing values.
+func (r *Request) SetQuery(key, value string) *Request {
+	r.queries.Set(key, value)
+	return r
+}
+
+// DelQuery deletes the values of key added to r.
+// Queries already present in the url are kept.
+func (r *Request) DelQuery(key string) *Request {
+	r.queries.Del(key)
+	return r
+}
+
+// Queries adds all values in queries, appending to any existing values.
+func (r *Request) Queries(queries url.Values) *Request {
+	for k, vs := range queries {
+		for _, v := range vs {
+			r.queries.Add(k, v)
+		}
+	}
+	return r
+}
+
+func (r *Request) ContentType(contentType string) *Request {
+	r.contentType = contentType
+	return r
+}
+
+// Header sets the header k to v, replacing any existing values.
+func (r *Request) Header(k, v string) *Request {
+	if k == "" || v == "" {
+		r.keepOriginErr(errors.New("invalid header, key or value is empty"))
+	} else {
+		r.header.Set(k, v)
+	}
+	return r
+}
+
+// AddHeader adds the value to header k, appending to any existing values.
+func (r *Request) AddHeader(k, v string) *Request {
+	if k == "" {
+		r.keepOriginErr(errors.New("invalid header, key is empty"))
+	} else {
+		r.header.Add(k, v)
+	}
+	return r
+}
+
+// SetHeader sets the header k to v, replacing any existing values.
+func (r *Request) SetHeader(k, v string) *Request {
+	if k == "" {
+		r.keepOriginErr(errors.New("invalid header, key is empty"))
+	} else {
+		r.header.Set(k, v)
+	}
+	return r
+}
+
+// DelHeader deletes the values of header k.
+func (r *Request) DelHeader(k string) *Request {
+	r.header.Del(k)
+	return r
+}
+
+// Headers adds all values in header, appending to any existing values.
+func (r *Request) Headers(header http.Header) *Request {
+	for k, vs := range header {
+		for _, v := range vs {
+			r.header.Add(k, v)
+		}
+	}
+	return r
+}
+
+// body can be defined struct, string, map, array(or slice), and so on.
+// Values other than strings, byte slices and readers are encoded with the
+// codec registered for the content type, see CodecFor; without a content
+// type they're encoded as JSON and Content-Type is set to ContentTypeJson.
+// An io.Reader body is streamed to the server without buffering; it can be
+// read only once, so such a request is never retried, use BodyFunc for that.
+func (r *Request) Body(body interface{}) *Request {
+	r.body, r.bodyValue, r.bodyReader, r.bodyFunc = nil, nil, nil, nil
+	r.contentLength = 0
+	switch value := body.(type) {
+	case string:
+		r.body = []byte(value)
+	case []byte:
+		r.body = value
+	case *os.File:
+		r.bodyReader = value
+		if info, err := value.Stat(); err == nil && info.Mode().IsRegular() {
+			r.contentLength = info.Size()
+		}
+	case io.Reader:
+		r.bodyReader = value
+	default:
+		r.bodyValue = body
+	}
+	return r
+}
+
+// BodyFunc sets a body streamed from the reader returned by fn.
+// fn is called again whenever the body needs to be reread, such as on
+// redirects and retries, so it should return a fresh reader each time.
+func (r *Request) BodyFunc(fn func() (io.ReadCloser, error)) *Request {
+	r.body, r.bodyValue, r.bodyReader, r.bodyFunc = nil, nil, nil, fn
+	r.contentLength = 0
+	return r
+}
+
+// ContentLength sets the length of a body set by an io.Reader or BodyFunc.
+// Without it, the length of such a body is unknown and it's sent chunked.
+func (r *Request) ContentLength(length int64) *Request {
+	r.contentLength = length
+	return r
+}
+
+func (r *Request) keepOriginErr(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *Request) Do(callback CallBack) {
+	r.DoContext(context.Background(), callback)
+}
+
+// DoContext is like Do, but the request is bound to ctx.
+func (r *Request) DoContext(ctx context.Context, callback CallBack) {
+	if callback == nil {
+		return
+	}
+	callback(r.GoContext(ctx))
+}
+
+func (r *Request) Go() (*http.Response, error) {
+	return r.GoContext(context.Background())
+}
+
+// GoContext is like Go, but the request is bound to ctx, so cancellation
+// and deadlines of ctx reach the dial, TLS handshake and body read.
+func (r *Request) GoContext(ctx context.Context) (*http.Response, error) {
+	if r.client.err != nil {
+		return nil, r.client.err
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	resp, err := r.do(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err = r.checkStatus(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// send makes the given attempt of the request, which starts from 1.
+// The attempt is traced, see TraceOf.
+func (r *Request) send(ctx context.Context, attempt int) (*http.Response, error) {
+	ctx, tracer := withTracer(ctx)
+	req, err := r.makeRequest(ctx)
+	if err != nil {
+		return nil, &finalError{fmt.Errorf("make request failed: %w", err)}
+	}
+	resp, err := r.client.logRoundTrip(req, attempt)
+	tracer.done()
+	return resp, err
+}
+
+func (r *Request) makeRequest(ctx context.Context) (*http.Request, error) {
+	var (
+		req *http.Request
+		err error
+	)
+
+	u, err := r.buildUrl()
+	if err != nil {
+		return nil, err
+	}
+	body, contentType, err := r.makeBody()
+	if err != nil {
+		return nil, err
+	}
+	req, err = http.NewRequestWithContext(ctx, r.method, u, body)
+	if err != nil {
+		if closer, ok := body.(io.Closer); ok {
+			_ = closer.Close()
+		}
+		return nil, err
+	}
+	switch {
+	case len(r.parts) > 0 && r.multipartReplayable():
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, _ := r.multipartBody()
+			return body, nil
+		}
+	case r.bodyFunc != nil:
+		req.GetBody = r.bodyFunc
+	}
+	if r.contentLength > 0 && (r.bodyFunc != nil || r.bodyReader != nil) {
+		req.ContentLength = r.contentLength
+	}
+
+	for k, vs := range r.header {
+		req.Header[k] = append([]string(nil), vs...)
+	}
+	if contentType != "" {
+		req.Header.Set(HeaderContentType, contentType)
+	}
+	return req, nil
+}
+
+// makeBody returns the request body and its content type.
+// A multipart body takes precedence over a form one, which takes precedence over Body.
+func (r *Request) makeBody() (io.Reader, string, error) {
+	switch {
+	case len(r.parts) > 0:
+		body, contentType := r.multipartBody()
+		return body, contentType, nil
+	case r.form != nil:
+		contentType := r.contentType
+		if contentType == "" {
+			contentType = ContentTypeForm
+		}
+		return strings.NewReader(r.form.Encode()), contentType, nil
+	case r.bodyFunc != nil:
+		body, err := r.bodyFunc()
+		return body, r.contentType, err
+	case r.bodyReader != nil:
+		return r.bodyReader, r.contentType, nil
+	case r.bodyValue != nil:
+		body, contentType, err := r.encodeBody()
+		return bytes.NewReader(body), contentType, err
+	default:
+		return bytes.NewReader(r.body), r.contentType, nil
+	}
+}
+
+// encodeBody encodes the body value with the codec of the content type.
+func (r *Request) encodeBody() ([]byte, string, error) {
+	var codec Codec = JSONCodec{}
+	contentType := r.contentType
+	if contentType == "" {
+		contentType = codec.ContentType()
+	} else if c, ok := CodecFor(contentType); ok {
+		codec = c
+	} else {
+		return nil, "", fmt.Errorf("no codec registered for content type %q", contentType)
+	}
+	body, err := codec.Marshal(r.bodyValue)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, contentType, nil
+}
+
+func (r *Request) debugBody() []byte {
+	if r.bodyValue == nil {
+		return r.body
+	}
+	body, _, err := r.encodeBody()
+	if err != nil {
+		return []byte(err.Error())
+	}
+	return body
+}
+
+// replayable reports whether the body can be sent again, e.g. on retries.
+func (r *Request) replayable() bool {
+	if len(r.parts) > 0 {
+		return r.multipartReplayable()
+	}
+	return r.form != nil || r.bodyReader == nil
+}
@@ -0,0 +1,98 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// DoString is like Do, but the response body is read, closed and passed
+// to callback as a string.
+func (r *Request) DoString(callback CallBackStr) {
+	if callback == nil {
+		return
+	}
+	resp, err := r.Go()
+	if err != nil {
+		callback(resp, "", err)
+		return
+	}
+	body, err := readAll(resp)
+	callback(resp, string(body), err)
+}
+
+// ToBytes sends the request and reads the whole response body into b.
+func (r *Request) ToBytes(b *[]byte) error {
+	_, body, err := r.read()
+	if err != nil {
+		return err
+	}
+	*b = body
+	return nil
+}
+
+// ToString sends the request and reads the whole response body into s.
+func (r *Request) ToString(s *string) error {
+	_, body, err := r.read()
+	if err != nil {
+		return err
+	}
+	*s = string(body)
+	return nil
+}
+
+// ToJSON sends the request and decodes the response body as JSON into v.
+func (r *Request) ToJSON(v interface{}) error {
+	_, body, err := r.read()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+// ToXML sends the request and decodes the response body as XML into v.
+func (r *Request) ToXML(v interface{}) error {
+	_, body, err := r.read()
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(body, v)
+}
+
+// Decode sends the request and decodes the response body into v,
+// with the codec registered for the response Content-Type, see CodecFor.
+// Responses without a registered codec are decoded as JSON.
+func (r *Request) Decode(v interface{}) error {
+	resp, body, err := r.read()
+	if err != nil {
+		return err
+	}
+	codec, ok := CodecFor(resp.Header.Get(HeaderContentType))
+	if !ok {
+		codec = JSONCodec{}
+	}
+	return codec.Unmarshal(body, v)
+}
+
+func (r *Request) read() (*http.Response, []byte, error) {
+	resp, err := r.Go()
+	if err != nil {
+		return nil, nil, err
+	}
+	body, err := readAll(resp)
+	return resp, body, err
+}
+
+// readAll reads and closes the response body.
+func readAll(resp *http.Response) ([]byte, error) {
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("read response body failed: %w", err)
+	}
+	return body, nil
+}
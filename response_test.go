@@ -0,0 +1,79 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseDecoding(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set(HeaderContentType, ContentTypeJson)
+			_, _ = w.Write([]byte(`{"Name":"Tom","Age":27}`))
+		case "/xml":
+			w.Header().Set(HeaderContentType, "application/xml; charset=utf-8")
+			_, _ = w.Write([]byte(`<Person><Name>Tom</Name><Age>27</Age></Person>`))
+		default:
+			_, _ = w.Write([]byte("Hello world"))
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	var p Person
+	if err := client.Get(server.URL + "/json").ToJSON(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Tom" || p.Age != 27 {
+		t.Errorf("unexpected person decoded from json: %+v", p)
+	}
+
+	p = Person{}
+	if err := client.Get(server.URL + "/xml").ToXML(&p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "Tom" || p.Age != 27 {
+		t.Errorf("unexpected person decoded from xml: %+v", p)
+	}
+
+	for _, path := range []string{"/json", "/xml"} {
+		p = Person{}
+		if err := client.Get(server.URL + path).Decode(&p); err != nil {
+			t.Fatal(path, err)
+		}
+		if p.Name != "Tom" || p.Age != 27 {
+			t.Errorf("unexpected person decoded from %s: %+v", path, p)
+		}
+	}
+
+	var s string
+	if err := client.Get(server.URL).ToString(&s); err != nil {
+		t.Fatal(err)
+	}
+	if s != "Hello world" {
+		t.Errorf("expected string %q, got %q", "Hello world", s)
+	}
+
+	var b []byte
+	if err := client.Get(server.URL).ToBytes(&b); err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != "Hello world" {
+		t.Errorf("expected bytes %q, got %q", "Hello world", b)
+	}
+
+	client.Get(server.URL).DoString(func(response *http.Response, body string, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body != "Hello world" {
+			t.Errorf("expected body %q, got %q", "Hello world", body)
+		}
+	})
+
+	if err := client.Get(server.URL + "/json").ToXML(&p); err == nil {
+		t.Error("expected an error decoding json as xml")
+	}
+}
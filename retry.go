@@ -0,0 +1,212 @@
+package httpclient
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	DefaultRetryMaxAttempts = 3
+	DefaultRetryMinBackoff  = 100 * time.Millisecond
+	DefaultRetryMaxBackoff  = 10 * time.Second
+	DefaultRetryMultiplier  = 2.0
+	DefaultRetryJitter      = 0.2
+
+	HeaderRetryAfter = "Retry-After"
+)
+
+var (
+	// DefaultRetryStatusCodes are the response statuses retried when
+	// RetryPolicy.StatusCodes is nil.
+	DefaultRetryStatusCodes = []int{
+		http.StatusTooManyRequests,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	}
+	// IdempotentMethods are the methods retried when RetryPolicy.Methods is nil.
+	IdempotentMethods = []string{GET, HEAD, PUT, DELETE, OPTIONS}
+)
+
+// RetryPolicy describes when and how often a request is re-executed.
+// Zero fields take the matching Default* values.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// MinBackoff is the wait before the second attempt; each following wait
+	// is multiplied by Multiplier, up to MaxBackoff.
+	MinBackoff time.Duration
+	// MaxBackoff is the longest wait between attempts. A response asking
+	// with Retry-After for a longer wait is returned without retrying.
+	MaxBackoff time.Duration
+	Multiplier float64
+	// Jitter is the fraction of each wait that is randomized, in [0, 1].
+	// Set it negative to disable jitter.
+	Jitter float64
+	// MaxElapsedTime stops retrying once the next attempt would start later
+	// than this after the first one. Zero means no limit.
+	MaxElapsedTime time.Duration
+	// StatusCodes are the response statuses to retry, in addition to
+	// network errors.
+	StatusCodes []int
+	// Methods are the request methods allowed to retry; add POST or PATCH
+	// here only if the server handles them idempotently.
+	Methods []string
+}
+
+// Retry makes requests sent by c re-executed according to policy.
+// A request is retried on network errors and on policy.StatusCodes,
+// honoring a Retry-After header sent by the server up to policy.MaxBackoff.
+func (c *Client) Retry(policy RetryPolicy) *Client {
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if policy.MinBackoff == 0 {
+		policy.MinBackoff = DefaultRetryMinBackoff
+	}
+	if policy.MaxBackoff == 0 {
+		policy.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if policy.Multiplier == 0 {
+		policy.Multiplier = DefaultRetryMultiplier
+	}
+	if policy.Jitter == 0 {
+		policy.Jitter = DefaultRetryJitter
+	}
+	if policy.StatusCodes == nil {
+		policy.StatusCodes = DefaultRetryStatusCodes
+	}
+	if policy.Methods == nil {
+		policy.Methods = IdempotentMethods
+	}
+	c.retry = &policy
+	return c
+}
+
+func (p *RetryPolicy) allowMethod(method string) bool {
+	for _, m := range p.Methods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// finalError is an error of an attempt not worth retrying, as it doesn't
+// come from the network, such as one returned by a hook or middleware.
+type finalError struct {
+	err error
+}
+
+func (e *finalError) Error() string {
+	return e.err.Error()
+}
+
+func (e *finalError) Unwrap() error {
+	return e.err
+}
+
+// unwrapFinal returns the error wrapped by a finalError, or err as it is.
+func unwrapFinal(err error) error {
+	if final, ok := err.(*finalError); ok {
+		return final.err
+	}
+	return err
+}
+
+// shouldRetry reports whether the result of an attempt is worth retrying.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		_, final := err.(*finalError)
+		return !final && ctx.Err() == nil
+	}
+	for _, code := range p.StatusCodes {
+		if resp.StatusCode == code {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the wait after the given attempt, which starts from 1.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := parseRetryAfter(resp.Header.Get(HeaderRetryAfter)); ok {
+			return wait
+		}
+	}
+	wait := float64(p.MinBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if wait > float64(p.MaxBackoff) {
+		wait = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		wait -= wait * p.Jitter * rand.Float64()
+	}
+	return time.Duration(wait)
+}
+
+// parseRetryAfter parses a Retry-After value, in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
+
+// do sends the request built by r, retrying it as the client's policy says.
+func (r *Request) do(ctx context.Context) (*http.Response, error) {
+	resp, err := r.retry(ctx)
+	return resp, unwrapFinal(err)
+}
+
+// retry is do, with the errors of the last attempt kept as they are.
+func (r *Request) retry(ctx context.Context) (*http.Response, error) {
+	policy := r.client.retry
+	if policy == nil || !policy.allowMethod(r.method) || !r.replayable() {
+		return r.send(ctx, 1)
+	}
+	start := time.Now()
+	for attempt := 1; ; attempt++ {
+		resp, err := r.send(ctx, attempt)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(ctx, resp, err) {
+			return resp, err
+		}
+		wait := policy.backoff(attempt, resp)
+		if wait > policy.MaxBackoff {
+			return resp, err
+		}
+		if policy.MaxElapsedTime > 0 && time.Since(start)+wait > policy.MaxElapsedTime {
+			return resp, err
+		}
+		if resp != nil {
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
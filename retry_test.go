@@ -0,0 +1,229 @@
+package httpclient
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry(t *testing.T) {
+	const body = "replayed body"
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ := ioutil.ReadAll(r.Body)
+		if string(got) != body {
+			t.Errorf("expected body %q on every attempt, got %q", body, got)
+		}
+		if atomic.AddInt64(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	client := New().Retry(RetryPolicy{MinBackoff: time.Millisecond})
+	client.Put(server.URL).Body(body).Do(func(response *http.Response, err error) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("expected status %d, got %d", http.StatusOK, response.StatusCode)
+		}
+	})
+	if attempts != 3 {
+		t.Errorf("expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryMethods(t *testing.T) {
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&attempts, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		methods  []string
+		expected int64
+	}{
+		{methods: nil, expected: 1},
+		{methods: []string{POST}, expected: 2},
+	}
+	for _, c := range cases {
+		atomic.StoreInt64(&attempts, 0)
+		client := New().Retry(RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond, Methods: c.methods})
+		client.Post(server.URL).Body("data").Do(drain)
+		if n := atomic.LoadInt64(&attempts); n != c.expected {
+			t.Errorf("methods %v: expected %d attempts, got %d", c.methods, c.expected, n)
+		}
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&attempts, 1) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			_ = conn.Close()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	resp, err := New().Retry(RetryPolicy{MinBackoff: time.Millisecond}).Get(server.URL).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", attempts)
+	}
+}
+
+func TestRetryFinalErrors(t *testing.T) {
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&attempts, 1)
+	}))
+	defer server.Close()
+
+	errAbort := errors.New("abort")
+	var calls int
+	count := func(req *http.Request) error {
+		calls++
+		return nil
+	}
+	cases := []struct {
+		name  string
+		build func(client *Client) *Request
+	}{
+		{name: "before hook", build: func(client *Client) *Request {
+			return client.OnBeforeRequest(func(*http.Request) error {
+				calls++
+				return errAbort
+			}).Get(server.URL)
+		}},
+		{name: "after hook", build: func(client *Client) *Request {
+			return client.OnBeforeRequest(count).OnAfterResponse(func(*http.Response) error {
+				return errAbort
+			}).Get(server.URL)
+		}},
+		{name: "middleware", build: func(client *Client) *Request {
+			return client.OnBeforeRequest(count).Use(func(next RoundTripFunc) RoundTripFunc {
+				return func(req *http.Request) (*http.Response, error) {
+					return nil, errAbort
+				}
+			}).Get(server.URL)
+		}},
+		{name: "missing path param", build: func(client *Client) *Request {
+			calls++
+			return client.Get(server.URL + "/{id}")
+		}},
+		{name: "body func", build: func(client *Client) *Request {
+			return client.Put(server.URL).BodyFunc(func() (io.ReadCloser, error) {
+				calls++
+				return nil, errAbort
+			})
+		}},
+	}
+	for _, c := range cases {
+		calls = 0
+		client := New().Retry(RetryPolicy{MinBackoff: time.Millisecond})
+		_, err := c.build(client).Go()
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+		}
+		if _, ok := err.(*finalError); ok {
+			t.Errorf("%s: expected the error returned as it is, got %#v", c.name, err)
+		}
+		if calls != 1 {
+			t.Errorf("%s: expected no retry, got %d attempts", c.name, calls)
+		}
+	}
+}
+
+func TestRetryMaxElapsedTime(t *testing.T) {
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&attempts, 1)
+		w.Header().Set(HeaderRetryAfter, "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client := New().Retry(RetryPolicy{MaxAttempts: 5, MaxBackoff: time.Minute, MaxElapsedTime: time.Second})
+	resp, err := client.Get(server.URL).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected the last response to be returned, got status %d", resp.StatusCode)
+	}
+	if attempts != 1 {
+		t.Errorf("expected Retry-After beyond the max elapsed time to stop retrying, got %d attempts", attempts)
+	}
+}
+
+func TestRetryAfterMaxBackoff(t *testing.T) {
+	var attempts int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&attempts, 1)
+		w.Header().Set(HeaderRetryAfter, "86400")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	start := time.Now()
+	resp, err := New().Retry(RetryPolicy{}).Get(server.URL).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || attempts != 1 {
+		t.Errorf("expected the response returned without retrying, got status %d after %d attempts", resp.StatusCode, attempts)
+	}
+	if elapsed := time.Since(start); elapsed > DefaultRetryMaxBackoff {
+		t.Errorf("expected no wait beyond the max backoff, took %v", elapsed)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+		Multiplier: 2,
+		Jitter:     -1,
+	}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, time.Second}
+	for i, e := range expected {
+		if wait := policy.backoff(i+1, nil); wait != e {
+			t.Errorf("attempt %d: expected backoff %v, got %v", i+1, e, wait)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if wait := policy.backoff(1, nil); wait < 50*time.Millisecond || wait > 100*time.Millisecond {
+			t.Fatalf("expected jittered backoff in [50ms, 100ms], got %v", wait)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{HeaderRetryAfter: {"1"}}}
+	if wait := policy.backoff(1, resp); wait != time.Second {
+		t.Errorf("expected Retry-After of 1s, got %v", wait)
+	}
+	resp.Header.Set(HeaderRetryAfter, time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat))
+	if wait := policy.backoff(1, resp); wait != 0 {
+		t.Errorf("expected Retry-After in the past to mean no wait, got %v", wait)
+	}
+}
@@ -0,0 +1,73 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// MaxStatusErrorBody is the max number of response body bytes kept in a StatusError.
+const MaxStatusErrorBody = 1024
+
+// StatusError is returned by Go when the response status isn't accepted
+// by CheckStatus or ExpectOK. The response body has been closed.
+type StatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Status     string
+	Header     http.Header
+	// Body holds at most MaxStatusErrorBody bytes of the response body.
+	Body []byte
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("%s %s: unexpected status %q", e.Method, e.URL, e.Status)
+	}
+	return fmt.Sprintf("%s %s: unexpected status %q: %s", e.Method, e.URL, e.Status, e.Body)
+}
+
+// CheckStatus makes Go return a *StatusError when the response status is
+// not one of codes.
+func (r *Request) CheckStatus(codes ...int) *Request {
+	r.acceptStatus = func(code int) bool {
+		for _, c := range codes {
+			if c == code {
+				return true
+			}
+		}
+		return false
+	}
+	return r
+}
+
+// ExpectOK makes Go return a *StatusError when the response status is not 2xx.
+func (r *Request) ExpectOK() *Request {
+	r.acceptStatus = func(code int) bool {
+		return code >= 200 && code < 300
+	}
+	return r
+}
+
+func (r *Request) checkStatus(resp *http.Response) error {
+	if r.acceptStatus == nil || r.acceptStatus(resp.StatusCode) {
+		return nil
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, MaxStatusErrorBody))
+	err := &StatusError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Header:     resp.Header,
+		Body:       body,
+	}
+	if resp.Request != nil {
+		err.Method = resp.Request.Method
+		err.URL = resp.Request.URL.String()
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package httpclient
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCheckStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/created":
+			w.WriteHeader(http.StatusCreated)
+		case "/fail":
+			w.Header().Set("X-Request-Id", "42")
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(strings.Repeat("x", MaxStatusErrorBody*2)))
+		}
+	}))
+	defer server.Close()
+
+	client := New()
+	resp, err := client.Get(server.URL + "/created").ExpectOK().Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+
+	_, err = client.Get(server.URL + "/created").CheckStatus(http.StatusOK).Go()
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *StatusError, got %v", err)
+	}
+	if statusErr.StatusCode != http.StatusCreated {
+		t.Errorf("expected status code %d, got %d", http.StatusCreated, statusErr.StatusCode)
+	}
+
+	resp, err = client.Post(server.URL + "/fail").ExpectOK().Go()
+	if resp != nil {
+		t.Error("expected no response with a status error")
+	}
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("expected a *StatusError, got %v", err)
+	}
+	if statusErr.Method != POST || statusErr.URL != server.URL+"/fail" {
+		t.Errorf("unexpected request in status error: %s %s", statusErr.Method, statusErr.URL)
+	}
+	if statusErr.Header.Get("X-Request-Id") != "42" {
+		t.Error("expected response headers in status error")
+	}
+	if len(statusErr.Body) != MaxStatusErrorBody {
+		t.Errorf("expected body snippet of %d bytes, got %d", MaxStatusErrorBody, len(statusErr.Body))
+	}
+
+	var v interface{}
+	if err = client.Get(server.URL + "/fail").ExpectOK().ToJSON(&v); !errors.As(err, &statusErr) {
+		t.Errorf("expected ToJSON to return a *StatusError, got %v", err)
+	}
+}
+
+func TestMakeRequestError(t *testing.T) {
+	_, err := New().Get("http://[::1]:namedport").Go()
+	var urlErr *url.Error
+	if !errors.As(err, &urlErr) {
+		t.Errorf("expected a wrapped *url.Error, got %v", err)
+	}
+}
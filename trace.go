@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Trace is the time spent in each phase of a request attempt, see TraceOf.
+// Phases that didn't happen, such as DNSLookup for an IP address or
+// Connect on a reused connection, are zero.
+type Trace struct {
+	DNSLookup    time.Duration
+	Connect      time.Duration
+	TLSHandshake time.Duration
+	// TimeToFirstByte is from the start of the attempt to the first
+	// response byte.
+	TimeToFirstByte time.Duration
+	// Total is from the start of the attempt to the response headers,
+	// including the redirects followed.
+	Total time.Duration
+	// ConnReused tells whether the connection came from the pool.
+	ConnReused bool
+	// RemoteAddr is the address of the server, empty if the transport
+	// didn't report it.
+	RemoteAddr string
+}
+
+func (t *Trace) String() string {
+	return fmt.Sprintf("dns=%v connect=%v tls=%v ttfb=%v total=%v reused=%t remote=%s",
+		t.DNSLookup, t.Connect, t.TLSHandshake, t.TimeToFirstByte, t.Total, t.ConnReused, t.RemoteAddr)
+}
+
+// TraceOf returns the trace of the attempt that got resp, as returned by
+// Request.Go, or nil if resp wasn't traced:
+//
+//	resp, err := client.Get(url).Go()
+//	...
+//	log.Println(httpclient.TraceOf(resp))
+func TraceOf(resp *http.Response) *Trace {
+	if resp == nil || resp.Request == nil {
+		return nil
+	}
+	t, ok := resp.Request.Context().Value(tracerKey{}).(*tracer)
+	if !ok {
+		return nil
+	}
+	return t.trace()
+}
+
+type tracerKey struct{}
+
+// tracer collects the times of the phases of a request from httptrace,
+// for TraceOf and for the timings of HAREntry.
+// Its hooks can be called concurrently, such as when dialing several addresses.
+// When a redirect is followed, the phases are the ones of the last request.
+type tracer struct {
+	mu           sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	dnsDone      time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	tlsDone      time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+	// end is when the response headers were returned
+	end        time.Time
+	reused     bool
+	remoteAddr net.Addr
+	localAddr  net.Addr
+}
+
+func newTracer() *tracer {
+	return &tracer{start: time.Now()}
+}
+
+// withTracer returns ctx traced by a new tracer, starting now, see TraceOf.
+func withTracer(ctx context.Context) (context.Context, *tracer) {
+	t := newTracer()
+	ctx = context.WithValue(ctx, tracerKey{}, t)
+	return httptrace.WithClientTrace(ctx, t.clientTrace()), t
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart:          func(httptrace.DNSStartInfo) { t.set(&t.dnsStart) },
+		DNSDone:           func(httptrace.DNSDoneInfo) { t.set(&t.dnsDone) },
+		ConnectStart:      func(string, string) { t.set(&t.connectStart) },
+		ConnectDone:       func(string, string, error) { t.set(&t.connectDone) },
+		TLSHandshakeStart: func() { t.set(&t.tlsStart) },
+		TLSHandshakeDone:  func(tls.ConnectionState, error) { t.set(&t.tlsDone) },
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			t.gotConn = time.Now()
+			t.reused = info.Reused
+			t.remoteAddr = info.Conn.RemoteAddr()
+			t.localAddr = info.Conn.LocalAddr()
+			if info.Reused {
+				// drop the phases of the requests redirected from
+				t.dnsStart, t.dnsDone, t.connectStart, t.connectDone = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+				t.tlsStart, t.tlsDone = time.Time{}, time.Time{}
+			}
+		},
+		WroteRequest:         func(httptrace.WroteRequestInfo) { t.set(&t.wroteRequest) },
+		GotFirstResponseByte: func() { t.set(&t.firstByte) },
+	}
+}
+
+func (t *tracer) set(at *time.Time) {
+	t.mu.Lock()
+	*at = time.Now()
+	t.mu.Unlock()
+}
+
+// done ends the request, once its response headers are returned.
+func (t *tracer) done() {
+	t.set(&t.end)
+}
+
+func (t *tracer) trace() *Trace {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := t.end
+	if end.IsZero() {
+		end = time.Now()
+	}
+	trace := &Trace{
+		DNSLookup:       span(t.dnsStart, t.dnsDone),
+		Connect:         span(t.connectStart, t.connectDone),
+		TLSHandshake:    span(t.tlsStart, t.tlsDone),
+		TimeToFirstByte: span(t.start, t.firstByte),
+		Total:           end.Sub(t.start),
+		ConnReused:      t.reused,
+	}
+	if t.remoteAddr != nil {
+		trace.RemoteAddr = t.remoteAddr.String()
+	}
+	if t.firstByte.IsZero() {
+		// the transport didn't report it, such as a mock one
+		trace.TimeToFirstByte = trace.Total
+	}
+	return trace
+}
+
+// span returns the time from start to end, zero if either is missing.
+func span(start, end time.Time) time.Duration {
+	if start.IsZero() || end.Before(start) {
+		return 0
+	}
+	return end.Sub(start)
+}
@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTrace(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = w.Write([]byte("pong"))
+	}))
+	defer server.Close()
+
+	client := New()
+	var traces []*Trace
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL).Go()
+		if err != nil {
+			t.Fatal(err)
+		}
+		drain(resp, nil)
+		traces = append(traces, TraceOf(resp))
+	}
+	first, second := traces[0], traces[1]
+	if first == nil || second == nil {
+		t.Fatal("expected the responses traced")
+	}
+	if first.ConnReused || first.Connect <= 0 || first.DNSLookup != 0 || first.TLSHandshake != 0 {
+		t.Errorf("unexpected trace of a new connection %v", first)
+	}
+	if first.RemoteAddr != server.Listener.Addr().String() {
+		t.Errorf("expected remote address %s, got %s", server.Listener.Addr(), first.RemoteAddr)
+	}
+	if first.TimeToFirstByte < 10*time.Millisecond || first.Total < first.TimeToFirstByte {
+		t.Errorf("unexpected ttfb %v and total %v", first.TimeToFirstByte, first.Total)
+	}
+	if !second.ConnReused || second.Connect != 0 {
+		t.Errorf("unexpected trace of a reused connection %v", second)
+	}
+}
+
+func TestTraceDNSAndTLS(t *testing.T) {
+	ca := newTestCA(t)
+	server := newTestTLSServer(t, ca)
+	defer server.Close()
+
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	resp, err := New().CertPool(ca.tlsConfig().RootCAs).ServerName("example.com").Get(url).Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	drain(resp, nil)
+	trace := TraceOf(resp)
+	if trace.DNSLookup <= 0 || trace.TLSHandshake <= 0 {
+		t.Errorf("expected dns and tls traced, got %v", trace)
+	}
+}
+
+func TestTraceOf(t *testing.T) {
+	if trace := TraceOf(nil); trace != nil {
+		t.Errorf("expected no trace, got %v", trace)
+	}
+	resp := &http.Response{Request: httptest.NewRequest(GET, "/", nil)}
+	if trace := TraceOf(resp); trace != nil {
+		t.Errorf("expected no trace, got %v", trace)
+	}
+
+	client := New().Transport(RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+	}))
+	resp, err := client.Get("http://example.com").Go()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := TraceOf(resp)
+	if trace == nil || trace.TimeToFirstByte != trace.Total || trace.RemoteAddr != "" {
+		t.Errorf("unexpected trace without transport hooks %v", trace)
+	}
+}